@@ -0,0 +1,248 @@
+package ip2location
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	UnsupportedArchiveError = errors.New("unsupported archive format")
+	ArchiveSizeError        = errors.New("archive member exceeds the size limit")
+)
+
+// DefaultMaxArchiveSize limits the decompressed size of archive members.
+const DefaultMaxArchiveSize = 4 << 30
+
+// Decompressor returns the decompressed contents of r.
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+var decompressors = struct {
+	sync.RWMutex
+	m map[string]Decompressor
+}{m: map[string]Decompressor{
+	".gz": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	".bz2": func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	},
+	".zst": func(r io.Reader) (io.ReadCloser, error) {
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	},
+}}
+
+// RegisterDecompressor registers a decompressor for files with extension ext, like ".xz",
+// for every caller of the package, replacing any previous one; a nil d removes it.
+// The package has decompressors for ".gz", ".bz2" and ".zst".
+func RegisterDecompressor(ext string, d Decompressor) {
+	decompressors.Lock()
+	defer decompressors.Unlock()
+	if d == nil {
+		delete(decompressors.m, strings.ToLower(ext))
+		return
+	}
+	decompressors.m[strings.ToLower(ext)] = d
+}
+
+func decompressor(name string) Decompressor {
+	decompressors.RLock()
+	defer decompressors.RUnlock()
+	return decompressors.m[strings.ToLower(path.Ext(name))]
+}
+
+// IsArchive reports whether OpenArchive can open the file name by its extension.
+func IsArchive(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".zip") || decompressor(name) != nil
+}
+
+// ArchiveOptions control how OpenArchive unpacks databases.
+type ArchiveOptions struct {
+	// MaxSize limits the decompressed size, DefaultMaxArchiveSize if zero.
+	MaxSize int64
+	// TempDir, if set, holds a temporary file for the decompressed database instead of memory.
+	// The file is removed on Close.
+	TempDir string
+	// Member is a path.Match pattern selecting the zip member, the first .bin member if empty.
+	Member string
+	// Decompressors maps file extensions, like ".xz", to decompressors used for this call only,
+	// ahead of the registered ones.
+	Decompressors map[string]Decompressor
+}
+
+func (o *ArchiveOptions) decompressor(name string) Decompressor {
+	ext := path.Ext(name)
+	for e, d := range o.Decompressors {
+		if strings.EqualFold(e, ext) {
+			return d
+		}
+	}
+	return decompressor(name)
+}
+
+// OpenArchive opens a database in a .zip archive or a compressed file.
+// Compressed files need a decompressor for their extension in opts or registered with RegisterDecompressor.
+// Zip members that are stored uncompressed are read in place.
+// The member name is reported by DBMeta.Member; for compressed files it is the file name without the extension.
+func OpenArchive(path string, opts ArchiveOptions) (*FileDB, error) {
+	return OpenFSArchive(os.DirFS(filepath.Dir(path)), filepath.Base(path), opts)
+}
+
+// OpenFSArchive is like OpenArchive for a file in fsys.
+func OpenFSArchive(fsys fs.FS, name string, opts ArchiveOptions) (*FileDB, error) {
+	if strings.EqualFold(path.Ext(name), ".zip") {
+		return openZip(fsys, name, opts)
+	}
+	d := opts.decompressor(name)
+	if d == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: UnsupportedArchiveError}
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rc, err := d(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	db, err := unpack(rc, opts)
+	if err != nil {
+		return nil, err
+	}
+	member := path.Base(name)
+	db.db.meta.member = strings.TrimSuffix(member, path.Ext(member))
+	return db, nil
+}
+
+func openZip(fsys fs.FS, name string, opts ArchiveOptions) (*FileDB, error) {
+	r, size, c, err := openReaderAt(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if c != nil {
+		defer c.Close()
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range zr.File {
+		if member.FileInfo().IsDir() || !opts.match(member.Name) {
+			continue
+		}
+		return openZipMember(fsys, name, member, opts)
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: MissingFileError}
+}
+
+func (o *ArchiveOptions) match(name string) bool {
+	if o.Member == "" {
+		return strings.EqualFold(path.Ext(name), ".bin")
+	}
+	ok, _ := path.Match(o.Member, name)
+	return ok
+}
+
+// openZipMember opens a database stored in a zip archive.
+// Stored members are read from the archive file, compressed members are unpacked.
+func openZipMember(fsys fs.FS, name string, member *zip.File, opts ArchiveOptions) (db *FileDB, err error) {
+	if member.Method == zip.Store {
+		offset, err := member.DataOffset()
+		if err != nil {
+			return nil, err
+		}
+		db = &FileDB{}
+		var archive io.ReaderAt
+		if archive, _, db.f, err = openReaderAt(fsys, name); err != nil {
+			return nil, err
+		}
+		if db.db, err = NewDB(io.NewSectionReader(archive, offset, int64(member.UncompressedSize64))); err != nil {
+			db.release()
+			return nil, err
+		}
+	} else {
+		rc, err := member.Open()
+		if err != nil {
+			return nil, err
+		}
+		db, err = unpack(rc, opts)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	db.db.meta.member = member.Name
+	return db, nil
+}
+
+// unpack reads a decompressed database into memory or a temporary file.
+func unpack(r io.Reader, opts ArchiveOptions) (*FileDB, error) {
+	max := opts.MaxSize
+	if max <= 0 {
+		max = DefaultMaxArchiveSize
+	}
+	r = io.LimitReader(r, max+1)
+	db := &FileDB{}
+	var ra io.ReaderAt
+	if opts.TempDir == "" {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > max {
+			return nil, ArchiveSizeError
+		}
+		ra = bytes.NewReader(data)
+	} else {
+		f, err := os.CreateTemp(opts.TempDir, "ip2location-*.bin")
+		if err != nil {
+			return nil, err
+		}
+		db.f = tempFile{f}
+		n, err := io.Copy(f, r)
+		if err == nil && n > max {
+			err = ArchiveSizeError
+		}
+		if err != nil {
+			db.release()
+			return nil, err
+		}
+		ra = f
+	}
+	var err error
+	if db.db, err = NewDB(ra); err != nil {
+		db.release()
+		return nil, err
+	}
+	return db, nil
+}
+
+// tempFile removes the file on Close.
+type tempFile struct {
+	*os.File
+}
+
+func (f tempFile) Close() error {
+	err := f.File.Close()
+	if e := os.Remove(f.Name()); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+package ip2location_test
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ip2loc "github.com/alxarch/ip2location-go"
+	"github.com/klauspost/compress/zstd"
+)
+
+func writeGzip(t *testing.T, path string, data []byte) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZstd(t *testing.T, path string, data []byte) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw, err := zstd.NewWriter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_OpenArchive(t *testing.T) {
+	data, err := os.ReadFile(binfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeGzip(t, filepath.Join(dir, "DB.BIN.gz"), data)
+	writeZstd(t, filepath.Join(dir, "DB.BIN.zst"), data)
+	writeGzip(t, filepath.Join(dir, "DB.BIN.xz"), data)
+	writeZip(t, filepath.Join(dir, "stored.zip"), data, zip.Store)
+	writeZip(t, filepath.Join(dir, "deflated.zip"), data, zip.Deflate)
+
+	ref, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	expect := ip2loc.Record{}
+	ref.Query("8.8.8.8", &expect, ip2loc.QueryAll)
+
+	tmp := t.TempDir()
+	for _, tc := range []struct {
+		name   string
+		opts   ip2loc.ArchiveOptions
+		member string
+	}{
+		{"DB.BIN.gz", ip2loc.ArchiveOptions{}, "DB.BIN"},
+		{"DB.BIN.gz", ip2loc.ArchiveOptions{TempDir: tmp}, "DB.BIN"},
+		{"DB.BIN.zst", ip2loc.ArchiveOptions{}, "DB.BIN"},
+		{"DB.BIN.zst", ip2loc.ArchiveOptions{TempDir: tmp}, "DB.BIN"},
+		{"stored.zip", ip2loc.ArchiveOptions{}, "IP2LOCATION/DB.BIN"},
+		{"deflated.zip", ip2loc.ArchiveOptions{TempDir: tmp, Member: "IP2LOCATION/*"}, "IP2LOCATION/DB.BIN"},
+	} {
+		db, err := ip2loc.OpenArchive(filepath.Join(dir, tc.name), tc.opts)
+		if err != nil {
+			t.Errorf("%s: %s", tc.name, err)
+			continue
+		}
+		meta := db.Meta()
+		if meta.Member() != tc.member {
+			t.Errorf("%s: member %q", tc.name, meta.Member())
+		}
+		x := ip2loc.Record{}
+		if db.Query("8.8.8.8", &x, ip2loc.QueryAll); x != expect {
+			t.Errorf("%s: query %+v", tc.name, x)
+		}
+		if err := db.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("Temporary files left %v", entries)
+	}
+
+	if _, err := ip2loc.OpenArchive(filepath.Join(dir, "DB.BIN.gz"), ip2loc.ArchiveOptions{MaxSize: int64(len(data) - 1)}); !errors.Is(err, ip2loc.ArchiveSizeError) {
+		t.Errorf("Opened archive above size limit %v", err)
+	}
+	if _, err := ip2loc.OpenArchive(filepath.Join(dir, "deflated.zip"), ip2loc.ArchiveOptions{Member: "*.csv"}); !errors.Is(err, ip2loc.MissingFileError) {
+		t.Errorf("Opened missing member %v", err)
+	}
+	xz := filepath.Join(dir, "DB.BIN.xz")
+	if _, err := ip2loc.OpenArchive(xz, ip2loc.ArchiveOptions{}); !errors.Is(err, ip2loc.UnsupportedArchiveError) {
+		t.Errorf("Opened unsupported archive %v", err)
+	}
+	// the test file is gzipped, a real decoder would handle xz
+	gz := func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	}
+	db, err := ip2loc.OpenArchive(xz, ip2loc.ArchiveOptions{Decompressors: map[string]ip2loc.Decompressor{".XZ": gz}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if ip2loc.IsArchive(xz) {
+		t.Error("Decompressor of one call registered")
+	}
+
+	ip2loc.RegisterDecompressor(".xz", gz)
+	t.Cleanup(func() {
+		ip2loc.RegisterDecompressor(".xz", nil)
+	})
+	fdb, err := ip2loc.NewFileDB(xz, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fdb.Close()
+}
@@ -0,0 +1,244 @@
+package ip2location
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"net/netip"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// RangeDB is a database that reports the block matching a query.
+// DB, FileDB and ReloadableDB implement it.
+type RangeDB interface {
+	IP2LocationDB
+	QueryRange(string, *Record, QueryMode) (Range, error)
+	QueryRangeContext(context.Context, string, *Record, QueryMode) (Range, error)
+}
+
+// reloader is implemented by databases that swap in new data while in use, such as ReloadableDB.
+type reloader interface {
+	// Generation changes after new data is swapped in.
+	Generation() uint64
+}
+
+const DefaultCacheSize = 1024
+
+// CachedDB caches records by the block they were found in,
+// so any address inside a cached block is a hit.
+// The cache is emptied whenever a ReloadableDB swaps in a new file.
+// It is safe for concurrent use.
+type CachedDB struct {
+	db   RangeDB
+	size int
+
+	mu     sync.Mutex
+	lru    list.List // *cacheEntry, most recently used first
+	blocks [2][]*cacheEntry
+	gen    uint64 // generation of the cached records
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type cacheEntry struct {
+	rng  Range
+	mode QueryMode
+	rec  Record
+	elem *list.Element
+}
+
+// CacheStats reports cache usage.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Size   int
+}
+
+// NewCachedDB caches up to size blocks of db.
+// A size less than 1 uses DefaultCacheSize.
+func NewCachedDB(db RangeDB, size int) *CachedDB {
+	if size < 1 {
+		size = DefaultCacheSize
+	}
+	c := &CachedDB{db: db, size: size}
+	c.gen = c.generation()
+	return c
+}
+
+func (c *CachedDB) Close() error {
+	return c.db.Close()
+}
+
+// SupportedModes returns the fields of the underlying database, if it reports them.
+func (c *CachedDB) SupportedModes() QueryMode {
+	if db, ok := c.db.(modeSupporter); ok {
+		return db.SupportedModes()
+	}
+	return 0
+}
+
+func (c *CachedDB) Stats() CacheStats {
+	c.mu.Lock()
+	size := c.lru.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Size:   size,
+	}
+}
+
+// Query looks up ip in the cache before querying the underlying database.
+// A cached record only satisfies queries for fields it was fetched with.
+func (c *CachedDB) Query(ip string, r *Record, mode QueryMode) error {
+	return c.QueryContext(context.Background(), ip, r, mode)
+}
+
+// QueryContext is like Query but stops when ctx is done.
+func (c *CachedDB) QueryContext(ctx context.Context, ip string, r *Record, mode QueryMode) error {
+	if err := ctx.Err(); err != nil {
+		return c.queryError(ip, mode, err)
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return c.queryError(ip, mode, InvalidAddressError)
+	}
+	n, t := AddrNumber(addr)
+	// strict queries hit only if the cached record has every field
+	strict := mode & QueryStrict
+	mode &^= QueryStrict
+
+	gen := c.generation()
+	c.mu.Lock()
+	c.flush(gen)
+	e := c.find(n, t)
+	if e != nil && e.mode&mode == mode && (strict == 0 || mode&^e.rec.Mode == 0) {
+		c.lru.MoveToFront(e.elem)
+		r.copyFrom(&e.rec, mode)
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return nil
+	}
+	// the caller gets only the requested fields
+	query := mode
+	if e != nil && strict == 0 {
+		// widen the query so the refreshed entry still serves earlier modes
+		query |= e.mode
+	}
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	x := Record{}
+	rng, err := c.db.QueryRangeContext(ctx, ip, &x, query|strict)
+	if err != nil {
+		return c.queryError(ip, mode|strict, err)
+	}
+	r.copyFrom(&x, mode)
+
+	c.mu.Lock()
+	// records of a database swapped out during the query are not cached
+	if c.generation() == gen {
+		c.flush(gen)
+		c.store(rng, &x, query)
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// queryError wraps err in a QueryError for the requested mode unless the underlying database already did.
+func (c *CachedDB) queryError(ip string, mode QueryMode, err error) error {
+	var qe *QueryError
+	if errors.As(err, &qe) {
+		if qe.Mode == mode {
+			return err
+		}
+		// report the requested fields rather than a widened query
+		e := *qe
+		e.Mode = mode
+		return &e
+	}
+	return &QueryError{IP: ip, Mode: mode, Supported: c.SupportedModes(), Err: err}
+}
+
+// generation returns the generation of the underlying database, zero if it never swaps in new data.
+func (c *CachedDB) generation() uint64 {
+	if db, ok := c.db.(reloader); ok {
+		return db.Generation()
+	}
+	return 0
+}
+
+// flush empties the cache if gen is newer than the cached records.
+func (c *CachedDB) flush(gen uint64) {
+	if gen <= c.gen {
+		return
+	}
+	c.gen = gen
+	c.lru.Init()
+	c.blocks = [2][]*cacheEntry{}
+}
+
+func blockIndex(t IPType) int {
+	if t == IPv6 {
+		return 1
+	}
+	return 0
+}
+
+// find returns the entry whose block contains n.
+func (c *CachedDB) find(n Uint128, t IPType) *cacheEntry {
+	blocks := c.blocks[blockIndex(t)]
+	i := sort.Search(len(blocks), func(i int) bool {
+		return n.Less(blocks[i].rng.From)
+	})
+	if i == 0 {
+		return nil
+	}
+	if e := blocks[i-1]; e.rng.Type == t && !e.rng.last().Less(n) {
+		return e
+	}
+	return nil
+}
+
+// store adds or updates the entry for rng and evicts the least recently used entries.
+func (c *CachedDB) store(rng Range, x *Record, mode QueryMode) {
+	b := blockIndex(rng.Type)
+	blocks := c.blocks[b]
+	i := sort.Search(len(blocks), func(i int) bool {
+		return !blocks[i].rng.From.Less(rng.From)
+	})
+	if i < len(blocks) && blocks[i].rng == rng {
+		e := blocks[i]
+		e.rec.copyFrom(x, mode)
+		e.mode |= mode
+		c.lru.MoveToFront(e.elem)
+		return
+	}
+	e := &cacheEntry{rng: rng, mode: mode, rec: *x}
+	e.elem = c.lru.PushFront(e)
+	blocks = append(blocks, nil)
+	copy(blocks[i+1:], blocks[i:])
+	blocks[i] = e
+	c.blocks[b] = blocks
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back().Value.(*cacheEntry))
+	}
+}
+
+func (c *CachedDB) remove(e *cacheEntry) {
+	c.lru.Remove(e.elem)
+	b := blockIndex(e.rng.Type)
+	blocks := c.blocks[b]
+	i := sort.Search(len(blocks), func(i int) bool {
+		return !blocks[i].rng.From.Less(e.rng.From)
+	})
+	if i < len(blocks) && blocks[i] == e {
+		copy(blocks[i:], blocks[i+1:])
+		blocks[len(blocks)-1] = nil
+		c.blocks[b] = blocks[:len(blocks)-1]
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"strings"
+
+	ip2location "github.com/alxarch/ip2location-go"
+)
+
+func runBulk(args []string) error {
+	fs := flag.NewFlagSet("bulk", flag.ContinueOnError)
+	f := queryFlags{}
+	f.register(fs, "csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	mode, err := f.mode()
+	if err != nil {
+		return err
+	}
+	db, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	// rows go straight to stdout so that output streams as input arrives
+	w, err := newRowWriter(stdout, f.format, mode)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() {
+		ip := strings.TrimSpace(scanner.Text())
+		if ip == "" || strings.HasPrefix(ip, "#") {
+			continue
+		}
+		x := ip2location.Record{}
+		if err := w.Write(ip, &x, db.Query(ip, &x, mode)); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
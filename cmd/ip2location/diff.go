@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	ip2location "github.com/alxarch/ip2location-go"
+)
+
+func runDiff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fields := flags.String("fields", "all", "comma separated fields to compare")
+	format := flags.String("format", "text", "output format: json or text")
+	summary := flags.Bool("summary", false, "only write the summary")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errors.New("diff needs the old and the new database file")
+	}
+	if *format != "json" && *format != "text" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	mode, err := ip2location.ParseQueryMode(*fields)
+	if err != nil {
+		return err
+	}
+	old, f, err := openFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cur, f, err := openFile(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(stdout)
+	d := ip2location.Diff(old, cur, mode)
+	if *format == "json" {
+		err = writeDiffJSON(w, d, *summary)
+	} else {
+		err = writeDiffText(w, d, *summary)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// writeDiffJSON writes a single object with the changed blocks and the summary, streaming the blocks.
+func writeDiffJSON(w io.Writer, d *ip2location.DiffCursor, summary bool) error {
+	enc := json.NewEncoder(w)
+	if !summary {
+		io.WriteString(w, `{"changes":[`)
+		for i := 0; d.Next(); i++ {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			if err := enc.Encode(d.Change()); err != nil {
+				return err
+			}
+		}
+		io.WriteString(w, `],"summary":`)
+	} else {
+		for d.Next() {
+		}
+		io.WriteString(w, `{"summary":`)
+	}
+	if err := d.Err(); err != nil {
+		return err
+	}
+	if err := enc.Encode(d.Stats()); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
+
+// writeDiffText writes a line for every changed block followed by the summary.
+func writeDiffText(w io.Writer, d *ip2location.DiffCursor, summary bool) error {
+	for d.Next() {
+		if summary {
+			continue
+		}
+		c := d.Change()
+		fmt.Fprintf(w, "%s\t%s-%s", c.Kind, c.Range.Start(), c.Range.End())
+		switch c.Kind {
+		case ip2location.Added:
+			fmt.Fprintf(w, "\t%s", c.New)
+		case ip2location.Removed:
+			fmt.Fprintf(w, "\t%s", c.Old)
+		case ip2location.Changed:
+			for _, m := range c.Fields.Fields() {
+				fmt.Fprintf(w, "\t%s: %v -> %v", m.Name(), c.Old.Value(m), c.New.Value(m))
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	if err := d.Err(); err != nil {
+		return err
+	}
+	s := d.Stats()
+	if !summary {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "compared:\t%s\n", d.Mode())
+	for _, c := range []struct {
+		name  string
+		count *ip2location.DiffCount
+	}{{"added", &s.Added}, {"removed", &s.Removed}, {"changed", &s.Changed}, {"unchanged", &s.Unchanged}} {
+		fmt.Fprintf(w, "%s:\t%d blocks, %s addresses\n", c.name, c.count.Blocks, c.count.Addresses)
+	}
+	names := make([]string, 0, len(s.Fields))
+	for name := range s.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s changed:\t%d blocks, %s addresses\n", name, s.Fields[name].Blocks, s.Fields[name].Addresses)
+	}
+	return nil
+}
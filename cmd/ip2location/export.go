@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	ip2location "github.com/alxarch/ip2location-go"
+)
+
+var notations = map[string]ip2location.AddressNotation{
+	"numeric": ip2location.NumericAddresses,
+	"text":    ip2location.TextAddresses,
+	"cidr":    ip2location.CIDRAddresses,
+}
+
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	path := flags.String("db", os.Getenv("IP2LOCATION_DB"), "database file")
+	fields := flags.String("fields", "all", "comma separated fields to export")
+	notation := flags.String("notation", "numeric", "address notation: numeric, text or cidr")
+	table := flags.String("table", "", "export only the ipv4 or ipv6 table")
+	header := flags.Bool("header", false, "write a header row")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		*path = flags.Arg(0)
+	}
+	if *path == "" {
+		return errors.New("no database, use -db or IP2LOCATION_DB")
+	}
+	opts := ip2location.ExportOptions{Header: *header}
+	var err error
+	if opts.Mode, err = ip2location.ParseQueryMode(*fields); err != nil {
+		return err
+	}
+	var ok bool
+	if opts.Notation, ok = notations[*notation]; !ok {
+		return fmt.Errorf("unknown notation %q", *notation)
+	}
+	switch *table {
+	case "":
+	case "ipv4":
+		opts.Type = ip2location.IPv4
+	case "ipv6":
+		opts.Type = ip2location.IPv6
+	default:
+		return fmt.Errorf("unknown table %q", *table)
+	}
+	db, f, err := openFile(*path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ip2location.Export(stdout, db, opts)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	ip2location "github.com/alxarch/ip2location-go"
+)
+
+// fileMeta is the header of a database file, marshaled with DBMeta.MarshalJSON.
+type fileMeta struct {
+	File string             `json:"file"`
+	Meta ip2location.DBMeta `json:"meta"`
+}
+
+// binFiles returns path if it is a file or the .bin files and archives below it if it is a directory.
+func binFiles(path string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == path && !d.IsDir() {
+			files = append(files, p)
+		} else if !d.IsDir() && (strings.EqualFold(filepath.Ext(p), ".bin") || ip2location.IsArchive(p)) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func runInfo(args []string) error {
+	flags := flag.NewFlagSet("info", flag.ContinueOnError)
+	f := dbFlags{}
+	f.register(flags)
+	format := flags.String("format", "table", "output format: json or table")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		f.path = flags.Arg(0)
+	}
+	if f.path == "" {
+		fmt.Fprintln(flags.Output(), "no database, use -db, IP2LOCATION_DB or a FILE or DIR argument")
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	files, err := binFiles(f.path)
+	if err != nil {
+		return err
+	}
+	infos := make([]fileMeta, 0, len(files))
+	for _, file := range files {
+		db, err := ip2location.NewFileDB(file, f.mmap)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		infos = append(infos, fileMeta{File: file, Meta: db.(*ip2location.FileDB).Meta()})
+		db.Close()
+	}
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(infos)
+	case "table":
+		w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		for i, info := range infos {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			m := &info.Meta
+			fmt.Fprintf(w, "file:\t%s\n", info.File)
+			if m.Member() != "" {
+				fmt.Fprintf(w, "member:\t%s\n", m.Member())
+			}
+			fmt.Fprintf(w, "type:\t%s\n", m.Type())
+			fmt.Fprintf(w, "date:\t%s\n", m.Date().Format("2006-01-02"))
+			fmt.Fprintf(w, "ipv4 blocks:\t%d\n", m.Count(ip2location.IPv4))
+			fmt.Fprintf(w, "ipv6 blocks:\t%d\n", m.Count(ip2location.IPv6))
+			fmt.Fprintf(w, "ipv4 index:\t%v\n", m.HasIndex(ip2location.IPv4))
+			fmt.Fprintf(w, "ipv6 index:\t%v\n", m.HasIndex(ip2location.IPv6))
+			fmt.Fprintf(w, "fields:\t%s\n", m.Type().Modes())
+		}
+		return w.Flush()
+	}
+	return fmt.Errorf("unknown format %q", *format)
+}
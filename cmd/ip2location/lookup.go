@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"flag"
+
+	ip2location "github.com/alxarch/ip2location-go"
+)
+
+func runLookup(args []string) error {
+	fs := flag.NewFlagSet("lookup", flag.ContinueOnError)
+	f := queryFlags{}
+	f.register(fs, "table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("no addresses to look up")
+	}
+	mode, err := f.mode()
+	if err != nil {
+		return err
+	}
+	db, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	w, err := newRowWriter(stdout, f.format, mode)
+	if err != nil {
+		return err
+	}
+	for _, ip := range fs.Args() {
+		x := ip2location.Record{}
+		if err := w.Write(ip, &x, db.Query(ip, &x, mode)); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
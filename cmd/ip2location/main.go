@@ -0,0 +1,133 @@
+// Command ip2location looks up addresses in IP2Location databases and inspects database files.
+//
+// Usage:
+//
+//	ip2location <command> [flags] [args]
+//
+// The commands are:
+//
+//	lookup  look up the addresses given as arguments
+//	bulk    look up addresses read from stdin, one per line
+//	info    describe database files
+//	serve   serve lookups over HTTP as JSON
+//	export  write the blocks of a database file as CSV
+//	mmdb    convert a database file to MaxMind DB format
+//	diff    compare two database files
+//
+// The database path is set with -db or the IP2LOCATION_DB environment variable.
+// A directory path loads every .bin file below it.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	ip2location "github.com/alxarch/ip2location-go"
+)
+
+// stdin and stdout are the standard streams of commands, replaced in tests.
+var (
+	stdin  io.Reader = os.Stdin
+	stdout io.Writer = os.Stdout
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"lookup": {"lookup [flags] IP...", runLookup},
+	"bulk":   {"bulk [flags] < ips.txt", runBulk},
+	"info":   {"info [flags] [FILE|DIR]", runInfo},
+	"serve":  {"serve [flags]", runServe},
+	"export": {"export [flags] [FILE]", runExport},
+	"mmdb":   {"mmdb [flags] [FILE]", runMMDB},
+	"diff":   {"diff [flags] OLD NEW", runDiff},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: ip2location <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  ip2location %s\n", commands[name].usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "ip2location:", err)
+		os.Exit(1)
+	}
+}
+
+// dbFlags are the flags shared by commands that open a database.
+type dbFlags struct {
+	path string
+	mmap bool
+}
+
+func (f *dbFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "db", os.Getenv("IP2LOCATION_DB"), "database file or directory")
+	fs.BoolVar(&f.mmap, "mmap", false, "memory map database files")
+}
+
+func (f *dbFlags) open() (ip2location.IP2LocationDB, error) {
+	if f.path == "" {
+		return nil, errors.New("no database, use -db or IP2LOCATION_DB")
+	}
+	return ip2location.NewFileDB(f.path, f.mmap)
+}
+
+// openFile opens a single database file for commands that walk its tables.
+// The file is read as needed rather than loaded into memory.
+func openFile(path string) (*ip2location.DB, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := ip2location.NewDB(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, f, nil
+}
+
+// queryFlags are the flags shared by commands that look up addresses.
+type queryFlags struct {
+	dbFlags
+	fields string
+	format string
+}
+
+func (f *queryFlags) register(fs *flag.FlagSet, format string) {
+	f.dbFlags.register(fs)
+	fs.StringVar(&f.fields, "fields", "all", "comma separated fields to look up")
+	fs.StringVar(&f.format, "format", format, "output format: json, csv or table")
+}
+
+func (f *queryFlags) mode() (ip2location.QueryMode, error) {
+	return ip2location.ParseQueryMode(f.fields)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ip2location "github.com/alxarch/ip2location-go"
+	"github.com/alxarch/ip2location-go/ip2locationtest"
+)
+
+// writeDB writes a DB5 database with GR and US blocks to dir.
+func writeDB(t *testing.T, dir, name string) string {
+	t.Helper()
+	spec := ip2locationtest.Spec{
+		Type: ip2location.DB5,
+		Blocks: []ip2locationtest.Block{
+			{Range: "1.0.0.0/24", Record: ip2locationtest.Record(ip2location.DB5.Modes(), "GR")},
+			{Range: "2001:db8::/32", Record: ip2locationtest.Record(ip2location.DB5.Modes(), "US")},
+		},
+	}
+	data, err := spec.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// run runs a command with input as stdin and returns its stdout.
+func run(t *testing.T, cmd func([]string) error, input string, args ...string) (string, error) {
+	t.Helper()
+	t.Setenv("IP2LOCATION_DB", "")
+	out := bytes.Buffer{}
+	stdin, stdout = strings.NewReader(input), &out
+	t.Cleanup(func() {
+		stdin, stdout = os.Stdin, os.Stdout
+	})
+	err := cmd(args)
+	return out.String(), err
+}
+
+func Test_Lookup(t *testing.T) {
+	path := writeDB(t, t.TempDir(), "db5.bin")
+	out, err := run(t, runLookup, "", "-db", path, "-fields", "country_code,city_name", "-format", "csv", "1.0.0.1", "2001:db8::1", "2.0.0.1", "nope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 || strings.Join(rows[0], ",") != "ip,country_code,city_name,error" {
+		t.Fatalf("Invalid output\n%s", out)
+	}
+	for i, want := range [][]string{
+		{"1.0.0.1", "GR", "city_name GR", ""},
+		{"2001:db8::1", "US", "city_name US", ""},
+	} {
+		if strings.Join(rows[i+1], ",") != strings.Join(want, ",") {
+			t.Errorf("Invalid row %v", rows[i+1])
+		}
+	}
+	if r := rows[3]; r[1] != "" || !strings.Contains(r[3], ip2location.NoMatchError.Error()) {
+		t.Errorf("Invalid no match row %v", r)
+	}
+	if r := rows[4]; !strings.Contains(r[3], ip2location.InvalidAddressError.Error()) {
+		t.Errorf("Invalid address row %v", r)
+	}
+
+	out, err = run(t, runLookup, "", "-db", path, "-fields", "country_code,latitude", "-format", "json", "1.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &v); err != nil {
+		t.Fatal(err)
+	}
+	want := ip2locationtest.Record(ip2location.DB5.Modes(), "GR")
+	if len(v) != 3 || v["ip"] != "1.0.0.1" || v["country_code"] != "GR" || v["latitude"] != float64(want.Latitude) {
+		t.Errorf("Invalid JSON output %s", out)
+	}
+
+	out, err = run(t, runLookup, "", "-db", path, "-fields", "region_name", "1.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 2 || strings.Fields(lines[0])[1] != "region_name" || !strings.Contains(lines[1], "region_name GR") {
+		t.Errorf("Invalid table output\n%s", out)
+	}
+
+	if _, err := run(t, runLookup, "", "-db", path); err == nil {
+		t.Error("Looked up without addresses")
+	}
+	if _, err := run(t, runLookup, "", "1.0.0.1"); err == nil {
+		t.Error("Looked up without database")
+	}
+	if _, err := run(t, runLookup, "", "-db", path, "-fields", "nope", "1.0.0.1"); err == nil {
+		t.Error("Looked up unknown field")
+	}
+	if _, err := run(t, runLookup, "", "-db", path, "-format", "xml", "1.0.0.1"); err == nil {
+		t.Error("Looked up with unknown format")
+	}
+}
+
+func Test_Bulk(t *testing.T) {
+	path := writeDB(t, t.TempDir(), "db5.bin")
+	out, err := run(t, runBulk, "1.0.0.1\n\n# comment\n 2001:db8::1 \n2.0.0.1\n", "-db", path, "-fields", "country_code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 || strings.Join(rows[1], ",") != "1.0.0.1,GR," || strings.Join(rows[2], ",") != "2001:db8::1,US," || rows[3][2] == "" {
+		t.Errorf("Invalid output\n%s", out)
+	}
+
+	out, err = run(t, runBulk, "1.0.0.1\n2001:db8::1\n", "-db", path, "-fields", "country_code", "-format", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "{\"ip\":\"1.0.0.1\",\"country_code\":\"GR\"}\n{\"ip\":\"2001:db8::1\",\"country_code\":\"US\"}\n" {
+		t.Errorf("Invalid JSON output\n%s", out)
+	}
+}
+
+func Test_BulkStreams(t *testing.T) {
+	path := writeDB(t, t.TempDir(), "db5.bin")
+	t.Setenv("IP2LOCATION_DB", "")
+	t.Cleanup(func() {
+		stdin, stdout = os.Stdin, os.Stdout
+	})
+	for format, prefix := range map[string]string{"csv": "%s,", "json": `{"ip":%q`} {
+		in, input := io.Pipe()
+		output, out := io.Pipe()
+		stdin, stdout = in, out
+		done := make(chan error, 1)
+		go func() {
+			done <- runBulk([]string{"-db", path, "-fields", "country_code", "-format", format})
+			out.Close()
+		}()
+		lines := make(chan string)
+		go func() {
+			scanner := bufio.NewScanner(output)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+			close(lines)
+		}()
+		for _, ip := range []string{"1.0.0.1", "2001:db8::1"} {
+			if _, err := io.WriteString(input, ip+"\n"); err != nil {
+				t.Fatal(err)
+			}
+			// the row arrives before more input does
+			for row := false; !row; {
+				select {
+				case line := <-lines:
+					row = strings.HasPrefix(line, fmt.Sprintf(prefix, ip))
+				case <-time.After(5 * time.Second):
+					t.Fatalf("%s: no row for %s before more input", format, ip)
+				}
+			}
+		}
+		input.Close()
+		for range lines {
+		}
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_Info(t *testing.T) {
+	dir := t.TempDir()
+	path := writeDB(t, dir, "a.bin")
+	writeDB(t, dir, "b.BIN")
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a database"), 0644)
+
+	out, err := run(t, runInfo, "", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"file:", path, "DB5", "2020-01-01", "ipv4 blocks:", "country_code,country_name,region_name,city_name,latitude,longitude"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Missing %q in output\n%s", s, out)
+		}
+	}
+
+	out, err = run(t, runInfo, "", "-db", dir, "-format", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var infos []struct {
+		File string
+		Meta json.RawMessage
+	}
+	if err := json.Unmarshal([]byte(out), &infos); err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[0].File != path || filepath.Base(infos[1].File) != "b.BIN" {
+		t.Fatalf("Invalid infos %v", infos)
+	}
+	// the header is marshaled by DBMeta
+	db, err := ip2location.OpenDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := json.Marshal(db.Meta())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := bytes.Buffer{}
+	if err := json.Compact(&got, infos[0].Meta); err != nil || got.String() != string(want) {
+		t.Errorf("Invalid info %s, expected %s", infos[0].Meta, want)
+	}
+	if !strings.Contains(string(want), `"type":"DB5"`) || !strings.Contains(string(want), `"ipv4_count":3`) {
+		t.Errorf("Invalid info %s", want)
+	}
+
+	if _, err := run(t, runInfo, ""); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Expected usage without database, got %v", err)
+	}
+	if _, err := run(t, runInfo, "", filepath.Join(dir, "missing.bin")); err == nil {
+		t.Error("Described missing file")
+	}
+	if _, err := run(t, runInfo, "", filepath.Join(dir, "notes.txt")); err == nil {
+		t.Error("Described invalid file")
+	}
+}
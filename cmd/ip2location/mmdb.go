@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	ip2location "github.com/alxarch/ip2location-go"
+	"github.com/alxarch/ip2location-go/ip2locationmmdb"
+)
+
+func runMMDB(args []string) error {
+	flags := flag.NewFlagSet("mmdb", flag.ContinueOnError)
+	path := flags.String("db", os.Getenv("IP2LOCATION_DB"), "database file")
+	fields := flags.String("fields", "all", "comma separated fields to convert")
+	out := flags.String("o", "", "output file, stdout if empty")
+	opts := ip2locationmmdb.Options{}
+	flags.StringVar(&opts.DatabaseType, "type", "", "database_type metadata, IP2Location-DBn by default")
+	flags.StringVar(&opts.Description, "description", "", "English description metadata")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		*path = flags.Arg(0)
+	}
+	if *path == "" {
+		return errors.New("no database, use -db or IP2LOCATION_DB")
+	}
+	var err error
+	if opts.Mode, err = ip2location.ParseQueryMode(*fields); err != nil {
+		return err
+	}
+	db, f, err := openFile(*path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if *out == "" {
+		return ip2locationmmdb.Convert(stdout, db, opts)
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := ip2locationmmdb.Convert(file, db, opts); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
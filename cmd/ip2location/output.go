@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	ip2location "github.com/alxarch/ip2location-go"
+)
+
+// rowWriter writes one row per looked up address.
+type rowWriter interface {
+	Write(ip string, x *ip2location.Record, err error) error
+	Flush() error
+}
+
+func newRowWriter(w io.Writer, format string, mode ip2location.QueryMode) (rowWriter, error) {
+	fields := mode.Fields()
+	switch format {
+	case "json":
+		return &jsonWriter{w: w}, nil
+	case "csv":
+		return &csvWriter{w: csv.NewWriter(w), fields: fields}, nil
+	case "table":
+		return &tableWriter{w: tabwriter.NewWriter(w, 0, 4, 2, ' ', 0), fields: fields}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// jsonWriter writes one JSON object per line with the populated fields in column order.
+type jsonWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (j *jsonWriter) Write(ip string, x *ip2location.Record, err error) error {
+	b := &j.buf
+	b.Reset()
+	if err != nil {
+		b.WriteString(`{"ip":`)
+		writeJSON(b, ip)
+		b.WriteString(`,"error":`)
+		writeJSON(b, err.Error())
+		b.WriteByte('}')
+	} else {
+		data, err := x.MarshalJSONWithIP(ip)
+		if err != nil {
+			return err
+		}
+		b.Write(data)
+	}
+	b.WriteByte('\n')
+	_, werr := j.w.Write(b.Bytes())
+	return werr
+}
+
+func writeJSON(b *bytes.Buffer, v interface{}) {
+	data, _ := json.Marshal(v)
+	b.Write(data)
+}
+
+func (j *jsonWriter) Flush() error {
+	return nil
+}
+
+// csvWriter writes a header followed by one record per address.
+type csvWriter struct {
+	w      *csv.Writer
+	fields []ip2location.QueryMode
+	header bool
+}
+
+func (c *csvWriter) Write(ip string, x *ip2location.Record, err error) error {
+	if !c.header {
+		c.header = true
+		if err := c.w.Write(header(c.fields)); err != nil {
+			return err
+		}
+	}
+	if err := c.w.Write(row(c.fields, ip, x, err)); err != nil {
+		return err
+	}
+	// flush per row so bulk output streams
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// tableWriter aligns columns and writes everything on Flush.
+type tableWriter struct {
+	w      *tabwriter.Writer
+	fields []ip2location.QueryMode
+	header bool
+}
+
+func (t *tableWriter) Write(ip string, x *ip2location.Record, err error) error {
+	if !t.header {
+		t.header = true
+		if _, err := fmt.Fprintln(t.w, strings.Join(header(t.fields), "\t")); err != nil {
+			return err
+		}
+	}
+	_, werr := fmt.Fprintln(t.w, strings.Join(row(t.fields, ip, x, err), "\t"))
+	return werr
+}
+
+func (t *tableWriter) Flush() error {
+	return t.w.Flush()
+}
+
+func header(fields []ip2location.QueryMode) []string {
+	h := make([]string, 0, len(fields)+2)
+	h = append(h, "ip")
+	for _, f := range fields {
+		h = append(h, f.Name())
+	}
+	return append(h, "error")
+}
+
+func row(fields []ip2location.QueryMode, ip string, x *ip2location.Record, err error) []string {
+	r := make([]string, 0, len(fields)+2)
+	r = append(r, ip)
+	for _, f := range fields {
+		if err != nil {
+			r = append(r, "")
+		} else {
+			r = append(r, x.Format(f))
+		}
+	}
+	return append(r, errString(err))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	ip2location "github.com/alxarch/ip2location-go"
+	"github.com/alxarch/ip2location-go/ip2locationhttp"
+)
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	f := dbFlags{}
+	f.register(fs)
+	addr := fs.String("addr", ":8080", "listen address")
+	watch := fs.Duration("watch", 0, "reload a database file when it changes, checking at this interval")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var db ip2location.IP2LocationDB
+	var err error
+	if *watch > 0 {
+		db, err = f.openReloadable(*watch)
+	} else {
+		db, err = f.open()
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           ip2locationhttp.NewHandler(db),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdown)
+}
+
+func (f *dbFlags) openReloadable(interval time.Duration) (ip2location.IP2LocationDB, error) {
+	if f.path == "" {
+		return nil, errors.New("no database, use -db or IP2LOCATION_DB")
+	}
+	db, err := ip2location.NewReloadableDB(f.path, f.mmap)
+	if err != nil {
+		return nil, err
+	}
+	db.OnReload = func(meta ip2location.DBMeta, err error) {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ip2location: reload:", err)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "ip2location: reloaded %s %s\n", meta.Type(), meta.Date().Format("2006-01-02"))
+	}
+	db.Watch(interval)
+	return db, nil
+}
@@ -1,10 +1,12 @@
 package ip2location
 
 import (
+	"context"
 	"errors"
 	"io"
 	"math/big"
 	"net"
+	"net/netip"
 	"strconv"
 )
 
@@ -35,84 +37,101 @@ const (
 	DB22
 	DB23
 	DB24
+	DB25
+	DB26
 	maxdb
 )
 
+// DB reads a database from an io.ReaderAt.
+//
+// A DB is safe for concurrent use by multiple goroutines, provided the ReaderAt
+// allows parallel ReadAt calls as io.ReaderAt requires. Concurrent queries must not share a Record.
 type DB struct {
 	r       io.ReaderAt
 	meta    DBMeta
-	offsets map[QueryMode]uint32
+	columns []column[QueryMode]
 	mode    QueryMode
 }
 
-type dbOffsetMap [25]uint8
+var (
+	MissingFileError            = errors.New("invalid database file")
+	NotSupportedError           = errors.New("parameter unavailable for selected data file, please upgrade the data file")
+	InvalidAddressError         = errors.New("invalid IP address")
+	UnsupportedAddressTypeError = errors.New("unsupported IP address type")
+	NoMatchError                = errors.New("no matching IP range found")
+	UnsupportedDatabaseError    = errors.New("unsupported database type")
+	DBClosedError               = errors.New("database is closed")
+)
 
-var offsetMaps = map[QueryMode]dbOffsetMap{
-	QueryCountryCode:        dbOffsetMap{0, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2},
-	QueryCountryName:        dbOffsetMap{0, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2},
-	QueryRegion:             dbOffsetMap{0, 0, 0, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3},
-	QueryCity:               dbOffsetMap{0, 0, 0, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4},
-	QueryISP:                dbOffsetMap{0, 0, 3, 0, 5, 0, 7, 5, 7, 0, 8, 0, 9, 0, 9, 0, 9, 0, 9, 7, 9, 0, 9, 7, 9},
-	QueryLatitude:           dbOffsetMap{0, 0, 0, 0, 0, 5, 5, 0, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
-	QueryLongitude:          dbOffsetMap{0, 0, 0, 0, 0, 6, 6, 0, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6},
-	QueryDomain:             dbOffsetMap{0, 0, 0, 0, 0, 0, 0, 6, 8, 0, 9, 0, 10, 0, 10, 0, 10, 0, 10, 8, 10, 0, 10, 8, 10},
-	QueryZipCode:            dbOffsetMap{0, 0, 0, 0, 0, 0, 0, 0, 0, 7, 7, 7, 7, 0, 7, 7, 7, 0, 7, 0, 7, 7, 7, 0, 7},
-	QueryTimeZone:           dbOffsetMap{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 8, 8, 7, 8, 8, 8, 7, 8, 0, 8, 8, 8, 0, 8},
-	QueryNetSpeed:           dbOffsetMap{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 8, 11, 0, 11, 8, 11, 0, 11, 0, 11, 0, 11},
-	QueryIDDCode:            dbOffsetMap{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 9, 12, 0, 12, 0, 12, 9, 12, 0, 12},
-	QueryAreaCode:           dbOffsetMap{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 13, 0, 13, 0, 13, 10, 13, 0, 13},
-	QueryWeatherStationCode: dbOffsetMap{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 9, 14, 0, 14, 0, 14, 0, 14},
-	QueryWeatherStationName: dbOffsetMap{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 15, 0, 15, 0, 15, 0, 15},
-	QueryMCC:                dbOffsetMap{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 9, 16, 0, 16, 9, 16},
-	QueryMNC:                dbOffsetMap{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 17, 0, 17, 10, 17},
-	QueryMobileBrand:        dbOffsetMap{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 11, 18, 0, 18, 11, 18},
-	QueryElevation:          dbOffsetMap{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 11, 19, 0, 19},
-	QueryUsageType:          dbOffsetMap{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 12, 20},
+// QueryError describes a failed query.
+// The cause is one of the error values of this package, a context error or a read error
+// and can be tested with errors.Is.
+// Errors of ProxyDB queries set only IP and Err.
+type QueryError struct {
+	IP        string
+	Type      DBType    // zero for errors of several databases
+	Mode      QueryMode // requested fields
+	Supported QueryMode // fields of the database
+	Err       error
 }
 
-var (
-	MissingFileError            = errors.New("Invalid database file.")
-	NotSupportedError           = errors.New("This parameter is unavailable for selected data file. Please upgrade the data file.")
-	InvalidAddressError         = errors.New("Invalid IP address.")
-	UnsupportedAddressTypeError = errors.New("Unsupported IP address type.")
-	NoMatchError                = errors.New("No matching IP range found.")
-)
+func (e *QueryError) Error() string {
+	msg := e.IP + ": " + e.Err.Error()
+	if e.Type != 0 {
+		msg = e.Type.String() + " " + msg
+	}
+	if missing := e.Missing(); missing != 0 && errors.Is(e.Err, NotSupportedError) {
+		msg += ": missing fields " + missing.String()
+	}
+	return msg
+}
+
+// Missing returns the requested fields that the database does not have.
+func (e *QueryError) Missing() QueryMode {
+	return e.Mode & QueryAll &^ e.Supported
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+func (db *DB) queryError(ip string, mode QueryMode, err error) error {
+	return &QueryError{IP: ip, Type: db.meta.dbtype, Mode: mode, Supported: db.mode, Err: err}
+}
 
 func NewDB(r io.ReaderAt) (db *DB, err error) {
 	db = &DB{r: r}
 	if err = db.meta.Read(r); err != nil {
 		return
 	}
-	dbt := db.meta.dbtype
-
-	db.offsets = make(map[QueryMode]uint32)
-	for m, ofm := range offsetMaps {
-		if pos := ofm[dbt]; pos != 0 {
-			// since both IPv4 and IPv6 use 4 bytes for the below columns, can just do it once here
-			db.offsets[m] = uint32(pos-1) << 2
-			db.mode |= m
-		}
+	if db.meta.dbtype >= maxdb {
+		return nil, UnsupportedDatabaseError
+	}
+	if db.columns, db.mode = layoutColumns(dbLayouts[db.meta.dbtype]); db.mode == 0 {
+		return nil, UnsupportedDatabaseError
 	}
 
 	return db, nil
 }
 
-func (db *DB) Close() {}
+func (db *DB) Close() error {
+	return nil
+}
 
-func (db *DB) Index(ip *big.Int, t IPType) uint32 {
-	switch t {
-	case IPv4:
-		tmp := big.NewInt(0)
-		tmp.Rsh(ip, 16)
-		tmp.Lsh(tmp, 3)
-		return uint32(tmp.Add(tmp, db.meta.ipv4bigidx).Uint64())
-	case IPv6:
-		tmp := big.NewInt(0)
-		tmp.Rsh(ip, 112)
-		tmp.Lsh(tmp, 3)
-		return uint32(tmp.Add(tmp, db.meta.ipv6bigidx).Uint64())
-	}
-	return 0
+// Meta returns the header of the database.
+func (db *DB) Meta() DBMeta {
+	return db.meta
+}
+
+// SupportedModes returns the fields of the database.
+// Queries fill only these fields; see Record.Mode and QueryStrict.
+func (db *DB) SupportedModes() QueryMode {
+	return db.mode
+}
+
+// Index returns the position of the first-16-bits index entry for ip.
+func (db *DB) Index(ip Uint128, t IPType) uint32 {
+	return db.meta.index(ip, t)
 }
 
 // get IP type and calculate IP number; calculates index too if exists
@@ -129,181 +148,163 @@ func ParseIP(ips string) (ip *big.Int, ipt IPType) {
 	}
 	return
 }
-func (db *DB) Lookup(ip *big.Int, t IPType) (lo, hi uint32) {
-	var idx uint32
-	switch t {
-	case IPv4:
-		lo = db.meta.ipv4addr
-		hi = db.meta.ipv4count * db.meta.ipv4colsize
-		if db.meta.ipv4index > 0 {
-			tmp := big.NewInt(0)
-			tmp.Rsh(ip, 16)
-			tmp.Lsh(tmp, 3)
-			idx = uint32(tmp.Add(tmp, db.meta.ipv4bigidx).Uint64())
-			if pos, err := readUint32(db.r, idx); err == nil {
-				lo = pos
-			}
-			if pos, err := readUint32(db.r, idx+4); err == nil {
-				hi = pos
-			}
-		}
-	case IPv6:
-		lo = db.meta.ipv6addr
-		hi = db.meta.ipv6count * db.meta.ipv6colsize
-
-		if db.meta.ipv4index > 0 {
-			tmp := big.NewInt(0)
-			tmp.Rsh(ip, 112)
-			tmp.Lsh(tmp, 3)
-			idx = uint32(tmp.Add(tmp, db.meta.ipv6bigidx).Uint64())
-		}
-	default:
-		return
-	}
-	if idx > 0 {
-		if pos, err := readUint32(db.r, idx); err == nil {
-			lo = pos
-		}
-		if pos, err := readUint32(db.r, idx+4); err == nil {
-			hi = pos
-		}
-	}
-	return
 
+// Lookup returns the row range to binary search for ip.
+func (db *DB) Lookup(ip Uint128, t IPType) (lo, hi uint32) {
+	return db.meta.lookup(db.r, ip, t)
 }
 
 // main Query
 func (db *DB) Query(ipaddress string, x *Record, mode QueryMode) (err error) {
-	// check IP type and return IP number & index (if exists)
-	ip, t := ParseIP(ipaddress)
-	return db.query(ip, t, x, mode)
+	return db.QueryContext(context.Background(), ipaddress, x, mode)
 }
 
-func (db *DB) query(ip *big.Int, ipt IPType, x *Record, mode QueryMode) (err error) {
-	if mode&db.mode == 0 {
-		return NotSupportedError
+// QueryContext is like Query but stops searching when ctx is done.
+func (db *DB) QueryContext(ctx context.Context, ipaddress string, x *Record, mode QueryMode) error {
+	_, err := db.QueryRangeContext(ctx, ipaddress, x, mode)
+	return err
+}
+
+// QueryAddr is like Query but takes a parsed address.
+// Apart from the strings stored in x it performs no heap allocations.
+func (db *DB) QueryAddr(addr netip.Addr, x *Record, mode QueryMode) error {
+	_, err := db.QueryAddrRange(addr, x, mode)
+	return err
+}
+
+// QueryRange is like Query but also returns the block that matched.
+func (db *DB) QueryRange(ipaddress string, x *Record, mode QueryMode) (Range, error) {
+	return db.QueryRangeContext(context.Background(), ipaddress, x, mode)
+}
+
+// QueryRangeContext is like QueryRange but stops searching when ctx is done.
+func (db *DB) QueryRangeContext(ctx context.Context, ipaddress string, x *Record, mode QueryMode) (Range, error) {
+	addr, err := netip.ParseAddr(ipaddress)
+	if err != nil {
+		return Range{}, db.queryError(ipaddress, mode, InvalidAddressError)
 	}
-	if !db.meta.Has(ipt) {
-		return UnsupportedAddressTypeError
+	ip, t := AddrNumber(addr)
+	rng, err := db.query(ctx, ip, t, x, mode)
+	if err != nil {
+		return Range{}, db.queryError(ipaddress, mode, err)
 	}
-	base, _, colsize, maxip := db.meta.Indexes(ipt)
-	var mid uint32
-	var ipfrom, ipto *big.Int
-	low, high := db.Lookup(ip, ipt)
+	return rng, nil
+}
 
-	if ip.Cmp(maxip) >= 0 {
-		ip = ip.Sub(ip, bigOne)
+// QueryAddrRange is like QueryAddr but also returns the block that matched.
+func (db *DB) QueryAddrRange(addr netip.Addr, x *Record, mode QueryMode) (Range, error) {
+	ip, t := AddrNumber(addr)
+	rng, err := db.query(context.Background(), ip, t, x, mode)
+	if err != nil {
+		return Range{}, db.queryError(addr.String(), mode, err)
 	}
+	return rng, nil
+}
 
-	var pos uint32
-	cs := uint32(colsize)
-	for low <= high {
-		mid = ((low + high) >> 1) // (low + high) / 2
-		o1 := uint32(base + (mid * colsize))
-		o2 := o1 + cs
+func (db *DB) query(ctx context.Context, ip Uint128, ipt IPType, x *Record, mode QueryMode) (Range, error) {
+	if ipt == 0 {
+		return Range{}, InvalidAddressError
+	}
+	if mode&db.mode == 0 || mode&QueryStrict != 0 && mode&QueryAll&^db.mode != 0 {
+		return Range{}, NotSupportedError
+	}
+	row, ipfrom, ipto, err := db.meta.search(ctx, db.r, ip, ipt)
+	if err != nil {
+		return Range{}, err
+	}
+	return Range{Type: ipt, From: ipfrom, To: ipto}, db.decode(row, x, mode)
+}
 
-		switch ipt {
-		case IPv4:
-			var ipn uint32
-			if ipn, err = readUint32(db.r, o1); err != nil {
-				return
-			} else {
-				ipfrom = big.NewInt(int64(ipn))
-			}
-			if ipn, err = readUint32(db.r, o2); err != nil {
-				return
-			} else {
-				ipto = big.NewInt(int64(ipn))
-			}
-		case IPv6:
-			if ipfrom, err = readUint128(db.r, o1); err != nil {
+// decode reads the columns selected by mode from a row into x.
+func (db *DB) decode(row uint32, x *Record, mode QueryMode) (err error) {
+	var pos uint32
+	for _, c := range db.columns {
+		m, mo := c.mode, c.offset
+		if mode&m == 0 {
+			// Query is not intereseted in mode
+			continue
+		}
+		switch m {
+		case QueryLatitude:
+			if x.Latitude, err = readFloat(db.r, row+mo); err != nil {
 				return
 			}
-			if ipto, err = readUint128(db.r, o2); err != nil {
+			x.Mode |= m
+			continue
+		case QueryLongitude:
+			if x.Longitude, err = readFloat(db.r, row+mo); err != nil {
 				return
 			}
-		default:
-			return InvalidAddressError
-		}
-
-		inrange := ip.Cmp(ipfrom) >= 0 && ip.Cmp(ipto) < 0
-		if !inrange {
-			if ip.Cmp(ipfrom) < 0 {
-				high = mid - 1
-			} else {
-				low = mid + 1
-			}
+			x.Mode |= m
 			continue
 		}
-
-		if ipt == IPv6 {
-			o1 += 12 // coz below is assuming all columns are 4 bytes, so got 12 left to go to make 16 bytes total
+		if pos, err = readUint32(db.r, row+mo); err != nil {
+			return
 		}
 
-		for m, mo := range db.offsets {
-			if mode&m == 0 {
-				// Query is not intereseted in mode
-				continue
-			}
-			if pos, err = readUint32(db.r, o1+mo); err != nil {
-				return
-			}
-
-			switch m {
-			case QueryCountryName:
-				x.CountryName, err = readString(db.r, pos+3)
-			case QueryCountryCode:
-				x.CountryCode, err = readString(db.r, pos)
-			case QueryRegion:
-				x.Region, err = readString(db.r, pos)
-			case QueryCity:
-				x.City, err = readString(db.r, pos)
-			case QueryISP:
-				x.ISP, err = readString(db.r, pos)
-			case QueryLatitude:
-				x.Latitude, err = rFloat(db.r, pos)
-			case QueryLongitude:
-				x.Longitude, err = rFloat(db.r, pos)
-			case QueryDomain:
-				x.Domain, err = readString(db.r, pos)
-			case QueryZipCode:
-				x.ZipCode, err = readString(db.r, pos)
-			case QueryTimeZone:
-				x.Timezone, err = readString(db.r, pos)
-			case QueryNetSpeed:
-				x.NetSpeed, err = readString(db.r, pos)
-			case QueryIDDCode:
-				x.IDDCode, err = readString(db.r, pos)
-			case QueryAreaCode:
-				x.Areacode, err = readString(db.r, pos)
-			case QueryWeatherStationCode:
-				x.WeatherStationCode, err = readString(db.r, pos)
-			case QueryWeatherStationName:
-				x.WeatherStationName, err = readString(db.r, pos)
-			case QueryMCC:
-				x.MCC, err = readString(db.r, pos)
-			case QueryMNC:
-				x.MNC, err = readString(db.r, pos)
-			case QueryMobileBrand:
-				x.MobileBrand, err = readString(db.r, pos)
-			case QueryUsageType:
-				x.UsageType, err = readString(db.r, pos)
-			case QueryElevation:
-				var s string
-				if s, err = readString(db.r, pos); err == nil {
-					x.Elevation, err = strconv.ParseFloat(s, 32)
-				}
-			}
-			if err != nil {
-				return
+		switch m {
+		case QueryCountryName:
+			x.CountryName, err = readString(db.r, pos+3)
+		case QueryCountryCode:
+			x.CountryCode, err = readString(db.r, pos)
+		case QueryRegion:
+			x.Region, err = readString(db.r, pos)
+		case QueryCity:
+			x.City, err = readString(db.r, pos)
+		case QueryISP:
+			x.ISP, err = readString(db.r, pos)
+		case QueryDomain:
+			x.Domain, err = readString(db.r, pos)
+		case QueryZipCode:
+			x.ZipCode, err = readString(db.r, pos)
+		case QueryTimeZone:
+			x.Timezone, err = readString(db.r, pos)
+		case QueryNetSpeed:
+			x.NetSpeed, err = readString(db.r, pos)
+		case QueryIDDCode:
+			x.IDDCode, err = readString(db.r, pos)
+		case QueryAreaCode:
+			x.Areacode, err = readString(db.r, pos)
+		case QueryWeatherStationCode:
+			x.WeatherStationCode, err = readString(db.r, pos)
+		case QueryWeatherStationName:
+			x.WeatherStationName, err = readString(db.r, pos)
+		case QueryMCC:
+			x.MCC, err = readString(db.r, pos)
+		case QueryMNC:
+			x.MNC, err = readString(db.r, pos)
+		case QueryMobileBrand:
+			x.MobileBrand, err = readString(db.r, pos)
+		case QueryUsageType:
+			x.UsageType, err = readString(db.r, pos)
+		case QueryAddressType:
+			x.AddressType, err = readString(db.r, pos)
+		case QueryCategory:
+			x.Category, err = readString(db.r, pos)
+		case QueryDistrict:
+			x.District, err = readString(db.r, pos)
+		case QueryASN:
+			x.ASN, err = readString(db.r, pos)
+		case QueryAS:
+			x.AS, err = readString(db.r, pos)
+		case QueryElevation:
+			var s string
+			if s, err = readString(db.r, pos); err == nil {
+				x.Elevation, err = strconv.ParseFloat(s, 32)
 			}
 		}
-		return nil
+		if err != nil {
+			return
+		}
+		x.Mode |= m
 	}
-	return NoMatchError
+	return nil
 }
 
+// IP2LocationDB is implemented by all databases of this package.
+// Implementations are safe for concurrent use unless documented otherwise.
 type IP2LocationDB interface {
 	Query(string, *Record, QueryMode) error
-	Close()
+	QueryContext(context.Context, string, *Record, QueryMode) error
+	Close() error
 }
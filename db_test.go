@@ -1,36 +1,81 @@
 package ip2location_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"log"
+	"math"
+	"net/netip"
 	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 
 	ip2loc "github.com/alxarch/ip2location-go"
-	orig "github.com/ip2location/ip2location-go"
+	"github.com/alxarch/ip2location-go/ip2locationtest"
 )
 
-var binfile string = "data/db.bin"
+// binfile is the database shared by tests, built from fixture unless IP2L_BINFILE points to a real one.
+var binfile string
 var dbfile *os.File
 
-func init() {
+// fixture covers both tables with contiguous blocks around the addresses tests query.
+var fixture = ip2locationtest.Spec{
+	Type: ip2loc.DB11,
+	Blocks: []ip2locationtest.Block{
+		{Range: "0.0.0.0-1.1.0.255", Record: ip2locationtest.Record(ip2loc.DB11.Modes(), "CN")},
+		{Range: "1.1.1.0/24", Record: ip2locationtest.Record(ip2loc.DB11.Modes(), "AU")},
+		{Range: "1.1.2.0-8.8.7.255", Record: ip2locationtest.Record(ip2loc.DB11.Modes(), "CN")},
+		{Range: "8.8.8.0/24", Record: ip2locationtest.Record(ip2loc.DB11.Modes(), "US")},
+		{Range: "8.8.9.0-127.255.255.255", Record: ip2locationtest.Record(ip2loc.DB11.Modes(), "CN")},
+		{Range: "128.0.0.0/1", Record: ip2locationtest.Record(ip2loc.DB11.Modes(), "GR")},
+		{Range: "::-2001:485f:ffff:ffff:ffff:ffff:ffff:ffff", Record: ip2locationtest.Record(ip2loc.DB11.Modes(), "CN")},
+		{Range: "2001:4860::/32", Record: ip2locationtest.Record(ip2loc.DB11.Modes(), "US")},
+		{Range: "2001:4861::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", Record: ip2locationtest.Record(ip2loc.DB11.Modes(), "GR")},
+	},
+}
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
 
+func run(m *testing.M) int {
 	if p, ok := os.LookupEnv("IP2L_BINFILE"); ok {
 		binfile = p
+	} else {
+		dir, err := os.MkdirTemp("", "ip2location")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		data, err := fixture.Bytes()
+		if err != nil {
+			log.Fatal(err)
+		}
+		binfile = filepath.Join(dir, "db.bin")
+		if err := os.WriteFile(binfile, data, 0644); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	f, err := os.Open(binfile)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer f.Close()
 	dbfile = f
-	orig.Open(binfile)
-
+	return m.Run()
 }
 
 func Test_Meta(t *testing.T) {
 	m := &ip2loc.DBMeta{}
 	if err := m.Read(dbfile); err != nil {
-		t.Error("Failed to init meta %s", err)
+		t.Errorf("Failed to init meta %s", err)
 	}
 
 	// log.Printf("%v", m)
@@ -38,10 +83,10 @@ func Test_Meta(t *testing.T) {
 func Test_NewDB(t *testing.T) {
 	db, err := ip2loc.NewDB(dbfile)
 	if err != nil {
-		t.Error("Failed to init db %s", err)
+		t.Errorf("Failed to init db %s", err)
 	}
 	if db == nil {
-		t.Error("Failed to init db %s", err)
+		t.Errorf("Failed to init db %s", err)
 
 	}
 	ip, ipt := ip2loc.ParseIP("127.0.0.1")
@@ -51,5 +96,562 @@ func Test_NewDB(t *testing.T) {
 	if ip.Int64() <= 0 {
 		t.Error("Invalid ip number")
 	}
+	n, ipt := ip2loc.AddrNumber(netip.MustParseAddr("127.0.0.1"))
+	if ipt != ip2loc.IPv4 || n.Big().Cmp(ip) != 0 {
+		t.Error("Invalid ip number")
+	}
+
+}
+
+func Test_QueryAddrAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("Allocations are not stable under the race detector")
+	}
+	db, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	addr := netip.MustParseAddr("8.8.8.8")
+	r := ip2loc.Record{}
+	allocs := testing.AllocsPerRun(100, func() {
+		db.QueryAddr(addr, &r, ip2loc.QueryLatitude|ip2loc.QueryLongitude)
+	})
+	if allocs != 0 {
+		t.Errorf("QueryAddr allocated %v times per run", allocs)
+	}
+}
+
+func benchmarkQueryAddr(b *testing.B, ip string, mode ip2loc.QueryMode) {
+	data, err := ioutil.ReadFile(binfile)
+	if err != nil {
+		b.Fatal(err)
+	}
+	db, err := ip2loc.NewDB(bytes.NewReader(data))
+	if err != nil {
+		b.Fatal(err)
+	}
+	addr := netip.MustParseAddr(ip)
+	r := ip2loc.Record{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.QueryAddr(addr, &r, mode)
+	}
+}
+
+func Benchmark_QueryAddrIPv4(b *testing.B) {
+	benchmarkQueryAddr(b, "8.8.8.8", ip2loc.QueryLatitude|ip2loc.QueryLongitude)
+}
+func Benchmark_QueryAddrIPv6(b *testing.B) {
+	benchmarkQueryAddr(b, "2001:4860:4860::8888", ip2loc.QueryLatitude|ip2loc.QueryLongitude)
+}
+func Benchmark_QueryAddrAll(b *testing.B) {
+	benchmarkQueryAddr(b, "8.8.8.8", ip2loc.QueryAll)
+}
+func Benchmark_Query(b *testing.B) {
+	db, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		b.Fatal(err)
+	}
+	r := ip2loc.Record{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		db.Query("8.8.8.8", &r, ip2loc.QueryCountryCode)
+	}
+}
+
+func Test_Ranges(t *testing.T) {
+	db, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	for _, ipt := range []ip2loc.IPType{ip2loc.IPv4, ip2loc.IPv6} {
+		c := db.Ranges(ipt, ip2loc.QueryCountryCode)
+		var last ip2loc.Range
+		for r, x := range c.All() {
+			if !last.To.IsZero() && r.From != last.To {
+				t.Errorf("Range %s not contiguous with %s", r.Start(), last.End())
+			}
+			if r.To.Less(r.From) {
+				t.Errorf("Invalid range %s-%s", r.Start(), r.End())
+			}
+			if x.CountryCode == "" {
+				t.Errorf("Missing country for %s", r.Start())
+			}
+			last = r
+		}
+		if err := c.Err(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func Test_QueryRange(t *testing.T) {
+	db, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	r := ip2loc.Record{}
+	rng, err := db.QueryRange("8.8.8.8", &r, ip2loc.QueryCountryCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rng.Contains(netip.MustParseAddr("8.8.8.8")) {
+		t.Errorf("Range %s-%s does not contain address", rng.Start(), rng.End())
+	}
+	for _, p := range rng.Prefixes() {
+		x := ip2loc.Record{}
+		if err := db.QueryAddr(p.Addr(), &x, ip2loc.QueryCountryCode); err != nil || x != r {
+			t.Errorf("Prefix %s does not match range record", p)
+		}
+	}
+}
+
+func Test_CachedDB(t *testing.T) {
+	db, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	c := ip2loc.NewCachedDB(db, 2)
+	r := ip2loc.Record{}
+	if err := c.Query("8.8.8.8", &r, ip2loc.QueryCountryCode); err != nil {
+		t.Fatal(err)
+	}
+	rng, _ := db.QueryRange("8.8.8.8", &ip2loc.Record{}, ip2loc.QueryCountryCode)
+	x := ip2loc.Record{}
+	if err := c.Query(rng.End().String(), &x, ip2loc.QueryCountryCode); err != nil || x != r {
+		t.Errorf("Invalid cached record %v", x)
+	}
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("Invalid stats %v", s)
+	}
+	if err := c.Query("8.8.8.8", &x, ip2loc.QueryCountryCode|ip2loc.QueryCountryName); err != nil {
+		t.Fatal(err)
+	}
+	if s := c.Stats(); s.Misses != 2 || s.Size != 1 {
+		t.Errorf("Wider mode served from cache %v", s)
+	}
+	c.Query("1.1.1.1", &x, ip2loc.QueryCountryCode)
+	c.Query("2001:4860:4860::8888", &x, ip2loc.QueryCountryCode)
+	if s := c.Stats(); s.Size != 2 {
+		t.Errorf("Cache size not bounded %v", s)
+	}
+	var qe *ip2loc.QueryError
+	if err := c.Query("nope", &x, ip2loc.QueryCountryCode); !errors.As(err, &qe) || !errors.Is(err, ip2loc.InvalidAddressError) {
+		t.Errorf("Invalid address error %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.QueryContext(ctx, "8.8.8.8", &x, ip2loc.QueryCountryCode); !errors.As(err, &qe) || !errors.Is(err, context.Canceled) {
+		t.Errorf("Invalid canceled error %v", err)
+	}
+	// errors of the underlying database are not wrapped twice
+	meta := db.Meta()
+	err = c.Query("8.8.8.8", &x, ip2loc.QueryCountryCode|ip2loc.QueryStrict|ip2loc.QueryAll)
+	if !errors.As(err, &qe) || qe.Type != meta.Type() || errors.As(qe.Err, new(*ip2loc.QueryError)) {
+		t.Errorf("Invalid database error %v", err)
+	}
+}
+
+func Test_CachedDBPartialHit(t *testing.T) {
+	db, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	c := ip2loc.NewCachedDB(db, 2)
+	if err := c.Query("8.8.8.8", &ip2loc.Record{}, ip2loc.QueryCountryCode); err != nil {
+		t.Fatal(err)
+	}
+	// the refresh is widened to the cached fields, the result is not
+	x := ip2loc.Record{}
+	if err := c.Query("8.8.8.8", &x, ip2loc.QueryCountryName); err != nil {
+		t.Fatal(err)
+	}
+	if x.Mode != ip2loc.QueryCountryName || x.CountryCode != "" {
+		t.Errorf("Invalid record %+v", x)
+	}
+	data, err := json.Marshal(&x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"country_name":` + strconv.Quote(x.CountryName) + `}`; string(data) != want {
+		t.Errorf("Invalid JSON %s", data)
+	}
+	if err := c.Query("8.8.8.8", &ip2loc.Record{}, ip2loc.QueryCountryCode); err != nil {
+		t.Fatal(err)
+	}
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 2 {
+		t.Errorf("Widened entry not cached %v", s)
+	}
+}
+
+func Test_CachedDBReload(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for _, code := range []string{"GR", "US"} {
+		spec := ip2locationtest.Spec{Type: ip2loc.DB1, Blocks: []ip2locationtest.Block{
+			{Range: "1.0.0.0/8", Record: ip2locationtest.Record(ip2loc.DB1.Modes(), code)},
+		}}
+		data, err := spec.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, code+".bin")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	rdb, err := ip2loc.NewReloadableDB(paths[0], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+	c := ip2loc.NewCachedDB(rdb, 0)
+	for i, path := range append(paths, paths[0]) {
+		if i > 0 {
+			gen := rdb.Generation()
+			if err := rdb.Reload(path); err != nil {
+				t.Fatal(err)
+			}
+			if rdb.Generation() == gen {
+				t.Error("Generation did not change on reload")
+			}
+		}
+		want := filepath.Base(path)[:2]
+		for _, ip := range []string{"1.0.0.1", "1.2.3.4"} {
+			x := ip2loc.Record{}
+			if err := c.Query(ip, &x, ip2loc.QueryCountryCode); err != nil || x.CountryCode != want {
+				t.Errorf("%d %s: invalid record %v %v", i, ip, x, err)
+			}
+		}
+		if s := c.Stats(); s.Size != 1 {
+			t.Errorf("%d: records of reloaded files kept %v", i, s)
+		}
+	}
+}
+
+func Test_ReloadableDB(t *testing.T) {
+	db, err := ip2loc.NewReloadableDB(binfile, false)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	if db.Date().IsZero() {
+		t.Error("Missing database date")
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r := ip2loc.Record{}
+		for i := 0; i < 1000; i++ {
+			if err := db.Query("8.8.8.8", &r, ip2loc.QueryCountryCode); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		if err := db.Reload(binfile); err != nil {
+			t.Error(err)
+		}
+	}
+	<-done
+	if err := db.Reload("db_test.go"); err == nil {
+		t.Error("Reloaded invalid file")
+	}
+	db.Close()
+	var qe *ip2loc.QueryError
+	if err := db.Query("8.8.8.8", &ip2loc.Record{}, ip2loc.QueryCountryCode); !errors.As(err, &qe) || !errors.Is(err, ip2loc.DBClosedError) || qe.IP != "8.8.8.8" {
+		t.Errorf("Query after close %v", err)
+	}
+	if _, err := db.QueryRangeContext(context.Background(), "8.8.8.8", &ip2loc.Record{}, ip2loc.QueryCountryCode); !errors.As(err, &qe) || !errors.Is(err, ip2loc.DBClosedError) || qe.Mode != ip2loc.QueryCountryCode {
+		t.Errorf("Query range after close %v", err)
+	}
+}
+
+func Test_ReloadableDBClosed(t *testing.T) {
+	data, err := os.ReadFile(binfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "db.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := ip2loc.NewReloadableDB(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloads := make(chan error, 1)
+	db.OnReload = func(_ ip2loc.DBMeta, err error) {
+		reloads <- err
+	}
+	db.Close()
+	if err := db.Reload(path); !errors.Is(err, ip2loc.DBClosedError) {
+		t.Errorf("Reloaded after close %v", err)
+	}
+	// a changed file is not picked up by watching a closed database
+	db.Watch(time.Millisecond)
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-reloads:
+		t.Errorf("Watched after close %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := db.Query("8.8.8.8", &ip2loc.Record{}, ip2loc.QueryCountryCode); !errors.Is(err, ip2loc.DBClosedError) {
+		t.Errorf("Query after close %v", err)
+	}
+}
 
+func Test_QueryContext(t *testing.T) {
+	db, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	fdb, err := ip2loc.NewFileDB(binfile, false)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	defer fdb.Close()
+	sdb := ip2loc.NewSafeDB(db)
+	defer sdb.Close()
+	dbs := []ip2loc.IP2LocationDB{
+		db,
+		fdb,
+		ip2loc.MultiDB{db, fdb},
+		&ip2loc.PoolDB{Factory: func() (ip2loc.IP2LocationDB, error) {
+			return ip2loc.NewDB(dbfile)
+		}},
+		sdb,
+		ip2loc.NewCachedDB(db, 0),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	for _, db := range dbs {
+		if err := db.QueryContext(ctx, "8.8.8.8", &ip2loc.Record{}, ip2loc.QueryCountryCode); err != nil {
+			t.Errorf("%T: %s", db, err)
+		}
+	}
+	cancel()
+	for _, db := range dbs {
+		if err := db.QueryContext(ctx, "8.8.8.8", &ip2loc.Record{}, ip2loc.QueryCountryCode); !errors.Is(err, context.Canceled) {
+			t.Errorf("%T: query with canceled context %v", db, err)
+		}
+	}
+}
+
+func Test_ParseQueryMode(t *testing.T) {
+	mode, err := ip2loc.ParseQueryMode("country_code, city_name,ASN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode != ip2loc.QueryCountryCode|ip2loc.QueryCity|ip2loc.QueryASN {
+		t.Errorf("Invalid mode %s", mode)
+	}
+	if mode.String() != "country_code,city_name,asn" {
+		t.Errorf("Invalid mode string %s", mode)
+	}
+	if mode, _ := ip2loc.ParseQueryMode(ip2loc.QueryAll.String()); mode != ip2loc.QueryAll {
+		t.Errorf("Invalid mode %s", mode)
+	}
+	if _, err := ip2loc.ParseQueryMode("city,nope"); err == nil {
+		t.Error("Parsed unknown field")
+	}
+}
+
+func Test_QueryError(t *testing.T) {
+	db, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	err = db.Query("invalid", &ip2loc.Record{}, ip2loc.QueryCity)
+	var qe *ip2loc.QueryError
+	if !errors.As(err, &qe) {
+		t.Fatalf("Invalid error %v", err)
+	}
+	meta := db.Meta()
+	if qe.IP != "invalid" || qe.Type != meta.Type() || qe.Mode != ip2loc.QueryCity || qe.Supported != meta.Type().Modes() {
+		t.Errorf("Invalid error context %+v", qe)
+	}
+	if !errors.Is(err, ip2loc.InvalidAddressError) {
+		t.Errorf("Lost cause %v", err)
+	}
+}
+
+func Test_SupportedModes(t *testing.T) {
+	db, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	meta := db.Meta()
+	supported := db.SupportedModes()
+	if supported != meta.Type().Modes() {
+		t.Errorf("Invalid supported modes %s", supported)
+	}
+	missing := ip2loc.QueryAll &^ supported
+	if missing == 0 {
+		t.Skip("Database has all fields")
+	}
+	for _, db := range []ip2loc.IP2LocationDB{db, ip2loc.MultiDB{db}, ip2loc.NewCachedDB(db, 0)} {
+		x := ip2loc.Record{}
+		if err := db.Query("8.8.8.8", &x, ip2loc.QueryAll); err != nil {
+			t.Fatalf("%T: %s", db, err)
+		}
+		if x.Mode != supported {
+			t.Errorf("%T: populated %s", db, x.Mode)
+		}
+		if err := db.Query("8.8.8.8", &ip2loc.Record{}, supported|ip2loc.QueryStrict); err != nil {
+			t.Errorf("%T: strict query %s", db, err)
+		}
+		err := db.Query("8.8.8.8", &ip2loc.Record{}, ip2loc.QueryAll|ip2loc.QueryStrict)
+		var qe *ip2loc.QueryError
+		if !errors.As(err, &qe) || !errors.Is(err, ip2loc.NotSupportedError) || qe.Missing() != missing {
+			t.Errorf("%T: strict query %v", db, err)
+		}
+	}
+	if m := (ip2loc.MultiDB{db, ip2loc.NewCachedDB(db, 0)}).SupportedModes(); m != supported {
+		t.Errorf("MultiDB supported modes %s", m)
+	}
+}
+
+func Test_DBTypes(t *testing.T) {
+	blocks := map[string]string{
+		"0.0.0.0":             "ZZ",
+		"1.0.0.0/24":          "GR",
+		"1.0.255.0-1.2.0.255": "FR",
+		"255.255.255.0/24":    "US",
+		"::/128":              "ZZ",
+		"2001:db8::/32":       "GR",
+		"ffff:ffff::/32":      "US",
+	}
+	queries := map[string]string{
+		"0.0.0.0":                                "ZZ",
+		"0.0.0.1":                                "",
+		"1.0.0.0":                                "GR",
+		"1.0.0.255":                              "GR",
+		"::ffff:1.0.0.1":                         "GR",
+		"1.0.1.0":                                "",
+		"1.0.255.0":                              "FR",
+		"1.1.128.0":                              "FR",
+		"1.2.0.255":                              "FR",
+		"1.2.1.0":                                "",
+		"255.255.254.255":                        "",
+		"255.255.255.0":                          "US",
+		"255.255.255.255":                        "US",
+		"::":                                     "ZZ",
+		"::1":                                    "",
+		"2001:db8::":                             "GR",
+		"2001:db8:ffff:ffff:ffff:ffff:ffff:ffff": "GR",
+		"2001:db9::":                             "",
+		"ffff:fffe:ffff:ffff:ffff:ffff:ffff:ffff": "",
+		"ffff:ffff::": "US",
+		"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff": "US",
+	}
+	for typ := ip2loc.DB1; typ <= ip2loc.DB26; typ++ {
+		for _, index := range []bool{true, false} {
+			spec := ip2locationtest.Spec{Type: typ, NoIndex: !index}
+			for r, code := range blocks {
+				spec.Blocks = append(spec.Blocks, ip2locationtest.Block{Range: r, Record: ip2locationtest.Record(typ.Modes(), code)})
+			}
+			db := ip2locationtest.New(t, spec)
+			meta := db.Meta()
+			if meta.Type() != typ || meta.HasIndex(ip2loc.IPv4) != index || meta.HasIndex(ip2loc.IPv6) != index {
+				t.Errorf("%s: invalid meta", typ)
+			}
+			for ip, code := range queries {
+				x := ip2loc.Record{}
+				err := db.Query(ip, &x, ip2loc.QueryAll)
+				if code == "" {
+					if !errors.Is(err, ip2loc.NoMatchError) {
+						t.Errorf("%s %s: expected no match, got %v", typ, ip, err)
+					}
+					continue
+				}
+				want := ip2locationtest.Record(typ.Modes(), code)
+				if err != nil || x != want {
+					t.Errorf("%s %s: invalid record %v %v", typ, ip, x, err)
+					continue
+				}
+				for _, m := range typ.Modes().Fields() {
+					x := ip2loc.Record{}
+					if err := db.Query(ip, &x, m); err != nil || x.Mode != m || x.Value(m) != want.Value(m) {
+						t.Errorf("%s %s: invalid %s %v %v", typ, ip, m.Name(), x.Value(m), err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Test_DBLayouts checks the columns written for each database type against the
+// official positions, counted from 1 for IPFrom, independently of the layouts the package uses.
+func Test_DBLayouts(t *testing.T) {
+	positions := map[ip2loc.QueryMode][27]int{
+		ip2loc.QueryCountryCode:        {0, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2},
+		ip2loc.QueryCountryName:        {0, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2},
+		ip2loc.QueryRegion:             {0, 0, 0, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3},
+		ip2loc.QueryCity:               {0, 0, 0, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4},
+		ip2loc.QueryISP:                {0, 0, 3, 0, 5, 0, 7, 5, 7, 0, 8, 0, 9, 0, 9, 0, 9, 0, 9, 7, 9, 0, 9, 7, 9, 9, 9},
+		ip2loc.QueryLatitude:           {0, 0, 0, 0, 0, 5, 5, 0, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+		ip2loc.QueryLongitude:          {0, 0, 0, 0, 0, 6, 6, 0, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6},
+		ip2loc.QueryDomain:             {0, 0, 0, 0, 0, 0, 0, 6, 8, 0, 9, 0, 10, 0, 10, 0, 10, 0, 10, 8, 10, 0, 10, 8, 10, 10, 10},
+		ip2loc.QueryZipCode:            {0, 0, 0, 0, 0, 0, 0, 0, 0, 7, 7, 7, 7, 0, 7, 7, 7, 0, 7, 0, 7, 7, 7, 0, 7, 7, 7},
+		ip2loc.QueryTimeZone:           {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 8, 8, 7, 8, 8, 8, 7, 8, 0, 8, 8, 8, 0, 8, 8, 8},
+		ip2loc.QueryNetSpeed:           {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 8, 11, 0, 11, 8, 11, 0, 11, 0, 11, 0, 11, 11, 11},
+		ip2loc.QueryIDDCode:            {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 9, 12, 0, 12, 0, 12, 9, 12, 0, 12, 12, 12},
+		ip2loc.QueryAreaCode:           {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 13, 0, 13, 0, 13, 10, 13, 0, 13, 13, 13},
+		ip2loc.QueryWeatherStationCode: {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 9, 14, 0, 14, 0, 14, 0, 14, 14, 14},
+		ip2loc.QueryWeatherStationName: {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 15, 0, 15, 0, 15, 0, 15, 15, 15},
+		ip2loc.QueryMCC:                {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 9, 16, 0, 16, 9, 16, 16, 16},
+		ip2loc.QueryMNC:                {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 17, 0, 17, 10, 17, 17, 17},
+		ip2loc.QueryMobileBrand:        {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 11, 18, 0, 18, 11, 18, 18, 18},
+		ip2loc.QueryElevation:          {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 11, 19, 0, 19, 19, 19},
+		ip2loc.QueryUsageType:          {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 12, 20, 20, 20},
+		ip2loc.QueryAddressType:        {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 21, 21},
+		ip2loc.QueryCategory:           {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 22, 22},
+		ip2loc.QueryDistrict:           {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 23},
+		ip2loc.QueryASN:                {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 24},
+		ip2loc.QueryAS:                 {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 25},
+	}
+	for typ := ip2loc.DB1; typ <= ip2loc.DB26; typ++ {
+		want := ip2locationtest.Record(typ.Modes(), "GR")
+		spec := ip2locationtest.Spec{Type: typ, NoIndex: true, Blocks: []ip2locationtest.Block{{Range: "0.0.0.0-255.255.255.255", Record: want}}}
+		data, err := spec.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the block is the first row of the IPv4 table
+		row := binary.LittleEndian.Uint32(data[9:]) - 1
+		str := func(pos uint32) string {
+			return string(data[pos+1 : pos+1+uint32(data[pos])])
+		}
+		var mode ip2loc.QueryMode
+		for m, pos := range positions {
+			p := pos[typ]
+			if p == 0 {
+				continue
+			}
+			mode |= m
+			v := binary.LittleEndian.Uint32(data[row+uint32(p-1)*4:])
+			switch m {
+			case ip2loc.QueryLatitude, ip2loc.QueryLongitude:
+				if got := math.Float32frombits(v); got != want.Value(m) {
+					t.Errorf("%s: invalid %s %v in column %d", typ, m.Name(), got, p)
+				}
+			case ip2loc.QueryCountryName:
+				if got := str(v + 3); got != want.Format(m) {
+					t.Errorf("%s: invalid %s %q in column %d", typ, m.Name(), got, p)
+				}
+			default:
+				if got := str(v); got != want.Format(m) {
+					t.Errorf("%s: invalid %s %q in column %d", typ, m.Name(), got, p)
+				}
+			}
+		}
+		if mode != typ.Modes() {
+			t.Errorf("%s: invalid fields %x", typ, typ.Modes())
+		}
+	}
 }
@@ -0,0 +1,323 @@
+package ip2location
+
+import (
+	"encoding/json"
+	"iter"
+	"math/big"
+)
+
+// DiffKind classifies a block returned by Diff.
+type DiffKind int
+
+const (
+	// Added blocks only match in the new database.
+	Added DiffKind = iota + 1
+	// Removed blocks only match in the old database.
+	Removed
+	// Changed blocks match in both databases with different fields.
+	Changed
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	}
+	return ""
+}
+
+func (k DiffKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Change is a block of addresses with the same records in both databases that differ.
+type Change struct {
+	Kind  DiffKind
+	Range Range
+	// Old and New are the records of the block, empty if it does not match.
+	Old, New Record
+	// Fields are the fields that differ in a changed block.
+	Fields QueryMode
+}
+
+// MarshalJSON writes the block as its first and last address and the records that match.
+func (c Change) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Kind   DiffKind `json:"kind"`
+		From   string   `json:"from"`
+		To     string   `json:"to"`
+		Fields []string `json:"fields,omitempty"`
+		Old    *Record  `json:"old,omitempty"`
+		New    *Record  `json:"new,omitempty"`
+	}{Kind: c.Kind, From: c.Range.Start().String(), To: c.Range.End().String()}
+	for _, m := range c.Fields.Fields() {
+		v.Fields = append(v.Fields, m.Name())
+	}
+	if c.Kind != Added {
+		v.Old = &c.Old
+	}
+	if c.Kind != Removed {
+		v.New = &c.New
+	}
+	return json.Marshal(v)
+}
+
+// DiffCount counts blocks and the addresses in them.
+type DiffCount struct {
+	Blocks    int      `json:"blocks"`
+	Addresses *big.Int `json:"addresses"`
+}
+
+func (c *DiffCount) add(r Range) {
+	if c.Addresses == nil {
+		c.Addresses = new(big.Int)
+	}
+	c.Blocks++
+	n := r.last().Big()
+	n.Sub(n, r.From.Big())
+	c.Addresses.Add(c.Addresses, n.Add(n, big.NewInt(1)))
+}
+
+// DiffStats summarizes the blocks read from a DiffCursor.
+type DiffStats struct {
+	Added     DiffCount `json:"added"`
+	Removed   DiffCount `json:"removed"`
+	Changed   DiffCount `json:"changed"`
+	Unchanged DiffCount `json:"unchanged"`
+	// Fields counts the changed blocks and addresses of every field by column name.
+	Fields map[string]*DiffCount `json:"fields"`
+}
+
+// diffSide is the block of a database at the current position of a DiffCursor.
+type diffSide struct {
+	c     *RangeCursor
+	rng   Range
+	rec   Record
+	valid bool
+}
+
+func (s *diffSide) next() error {
+	if s.valid = s.c.Next(); s.valid {
+		s.rng, s.rec = s.c.Range(), *s.c.Record()
+	}
+	return s.c.Err()
+}
+
+// DiffCursor iterates over the blocks that differ between two databases in address order,
+// the IPv4 table before the IPv6 table.
+// Adjacent blocks with the same records are reported once.
+type DiffCursor struct {
+	old, cur *DB
+	mode     QueryMode
+	types    []IPType
+	a, b     diffSide
+	pos      Uint128
+	started  bool
+	change   Change
+	pending  *Change
+	// unchanged is the last unchanged block, counted once with the blocks it continues
+	unchanged Change
+	stats     DiffStats
+	err       error
+}
+
+// Diff compares the fields of mode in the tables of two databases.
+// Only fields that both databases have are compared and a zero mode compares all of them.
+func Diff(old, cur *DB, mode QueryMode) *DiffCursor {
+	common := QueryAll & old.mode & cur.mode
+	if mode != 0 {
+		common &= mode
+	}
+	d := &DiffCursor{old: old, cur: cur, mode: common, types: []IPType{IPv4, IPv6}}
+	d.stats.Fields = map[string]*DiffCount{}
+	for _, c := range []*DiffCount{&d.stats.Added, &d.stats.Removed, &d.stats.Changed, &d.stats.Unchanged} {
+		c.Addresses = new(big.Int)
+	}
+	if common == 0 {
+		d.err = NotSupportedError
+	}
+	return d
+}
+
+// Mode returns the compared fields.
+func (d *DiffCursor) Mode() QueryMode {
+	return d.mode
+}
+
+// segment returns the next part of a table where both databases have the same block or no block.
+func (d *DiffCursor) segment() (c Change, unchanged, ok bool) {
+	for {
+		if !d.started {
+			if len(d.types) == 0 {
+				return Change{}, false, false
+			}
+			t := d.types[0]
+			d.types = d.types[1:]
+			d.a = diffSide{c: d.old.Ranges(t, d.mode)}
+			d.b = diffSide{c: d.cur.Ranges(t, d.mode)}
+			if d.err = d.a.next(); d.err != nil {
+				return
+			}
+			if d.err = d.b.next(); d.err != nil {
+				return
+			}
+			d.pos, d.started = Uint128{}, true
+		}
+		t := d.a.c.t
+		if !d.a.valid && !d.b.valid {
+			d.started = false
+			continue
+		}
+		end := t.max()
+		inA := d.a.valid && !d.pos.Less(d.a.rng.From)
+		inB := d.b.valid && !d.pos.Less(d.b.rng.From)
+		for _, s := range []*diffSide{&d.a, &d.b} {
+			if !s.valid {
+				continue
+			}
+			if !d.pos.Less(s.rng.From) {
+				end = minUint128(end, s.rng.last())
+			} else {
+				end = minUint128(end, s.rng.From.Sub1())
+			}
+		}
+		c = Change{Range: Range{Type: t, From: d.pos, To: end}}
+		if end != t.max() {
+			c.Range.To = end.Add1()
+		}
+		switch {
+		case inA && inB:
+			c.Old, c.New = d.a.rec, d.b.rec
+			for _, m := range d.mode.Fields() {
+				if c.Old.Value(m) != c.New.Value(m) {
+					c.Fields |= m
+				}
+			}
+			if c.Fields != 0 {
+				c.Kind = Changed
+			} else {
+				unchanged = true
+			}
+		case inA:
+			c.Kind, c.Old = Removed, d.a.rec
+		case inB:
+			c.Kind, c.New = Added, d.b.rec
+		}
+		for _, s := range []*diffSide{&d.a, &d.b} {
+			if s.valid && s.rng.last() == end {
+				if d.err = s.next(); d.err != nil {
+					return
+				}
+			}
+		}
+		if end == t.max() {
+			d.started = false
+		} else {
+			d.pos = end.Add1()
+		}
+		if c.Kind != 0 || unchanged {
+			return c, unchanged, true
+		}
+	}
+}
+
+func minUint128(a, b Uint128) Uint128 {
+	if b.Less(a) {
+		return b
+	}
+	return a
+}
+
+// Next advances the cursor to the next block that differs.
+// It returns false after the last block or on error.
+func (d *DiffCursor) Next() bool {
+	for d.err == nil {
+		c, unchanged, ok := d.segment()
+		if d.err != nil {
+			return false
+		}
+		if ok && unchanged {
+			if adjacent(&d.unchanged, &c) {
+				d.stats.Unchanged.Blocks--
+			}
+			d.stats.Unchanged.add(c.Range)
+			d.unchanged = c
+		}
+		if p := d.pending; p != nil && ok && !unchanged && adjacent(p, &c) {
+			p.Range.To = c.Range.To
+			continue
+		}
+		if p := d.pending; p != nil {
+			d.pending = nil
+			if ok && !unchanged {
+				d.pending = &c
+			}
+			d.emit(*p)
+			return true
+		}
+		if !ok {
+			return false
+		}
+		if !unchanged {
+			d.pending = &c
+		}
+	}
+	return false
+}
+
+// adjacent reports whether c continues the block of p with the same records.
+func adjacent(p, c *Change) bool {
+	return p.Kind == c.Kind && p.Range.Type == c.Range.Type && p.Range.To == c.Range.From &&
+		p.Old == c.Old && p.New == c.New
+}
+
+func (d *DiffCursor) emit(c Change) {
+	d.change = c
+	switch c.Kind {
+	case Added:
+		d.stats.Added.add(c.Range)
+	case Removed:
+		d.stats.Removed.add(c.Range)
+	case Changed:
+		d.stats.Changed.add(c.Range)
+		for _, m := range c.Fields.Fields() {
+			f := d.stats.Fields[m.Name()]
+			if f == nil {
+				f = &DiffCount{}
+				d.stats.Fields[m.Name()] = f
+			}
+			f.add(c.Range)
+		}
+	}
+}
+
+// Change returns the current block.
+func (d *DiffCursor) Change() *Change {
+	return &d.change
+}
+
+// Stats returns the summary of the blocks read so far, complete once Next returns false.
+func (d *DiffCursor) Stats() *DiffStats {
+	return &d.stats
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (d *DiffCursor) Err() error {
+	return d.err
+}
+
+// All returns an iterator over the remaining blocks.
+// Check Err after the loop completes.
+func (d *DiffCursor) All() iter.Seq[*Change] {
+	return func(yield func(*Change) bool) {
+		for d.Next() {
+			if !yield(&d.change) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package ip2location_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	ip2loc "github.com/alxarch/ip2location-go"
+	"github.com/alxarch/ip2location-go/ip2locationtest"
+)
+
+func Test_Diff(t *testing.T) {
+	gr := ip2locationtest.Record(ip2loc.DB3.Modes(), "GR")
+	fr := ip2locationtest.Record(ip2loc.DB3.Modes(), "FR")
+	us := ip2locationtest.Record(ip2loc.DB3.Modes(), "US")
+	athina := gr
+	athina.City = "Athina"
+	old := ip2locationtest.New(t, ip2locationtest.Spec{
+		Type: ip2loc.DB3,
+		Blocks: []ip2locationtest.Block{
+			{Range: "1.0.0.0/24", Record: gr},
+			{Range: "1.0.1.0/24", Record: fr},
+			{Range: "2.0.0.0/24", Record: us},
+			{Range: "4.0.0.0/24", Record: gr},
+		},
+	})
+	new := ip2locationtest.New(t, ip2locationtest.Spec{
+		Type: ip2loc.DB3,
+		Blocks: []ip2locationtest.Block{
+			{Range: "1.0.0.0/24", Record: athina},
+			{Range: "1.0.1.0/25", Record: fr},
+			{Range: "1.0.1.128/25", Record: fr},
+			{Range: "3.0.0.0/24", Record: us},
+			{Range: "4.0.0.0/25", Record: us},
+			{Range: "4.0.0.128/25", Record: us},
+			{Range: "2001:db8::/32", Record: fr},
+		},
+	})
+	d := ip2loc.Diff(old, new, 0)
+	var changes []string
+	for c := range d.All() {
+		data, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes = append(changes, string(data))
+	}
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		`{"kind":"changed","from":"1.0.0.0","to":"1.0.0.255","fields":["city_name"],"old":{"country_code":"GR","country_name":"country_name GR","region_name":"region_name GR","city_name":"city_name GR"},"new":{"country_code":"GR","country_name":"country_name GR","region_name":"region_name GR","city_name":"Athina"}}`,
+		`{"kind":"removed","from":"2.0.0.0","to":"2.0.0.255","old":{"country_code":"US","country_name":"country_name US","region_name":"region_name US","city_name":"city_name US"}}`,
+		`{"kind":"added","from":"3.0.0.0","to":"3.0.0.255","new":{"country_code":"US","country_name":"country_name US","region_name":"region_name US","city_name":"city_name US"}}`,
+		`{"kind":"changed","from":"4.0.0.0","to":"4.0.0.255","fields":["country_code","country_name","region_name","city_name"],"old":{"country_code":"GR","country_name":"country_name GR","region_name":"region_name GR","city_name":"city_name GR"},"new":{"country_code":"US","country_name":"country_name US","region_name":"region_name US","city_name":"city_name US"}}`,
+		`{"kind":"added","from":"2001:db8::","to":"2001:db8:ffff:ffff:ffff:ffff:ffff:ffff","new":{"country_code":"FR","country_name":"country_name FR","region_name":"region_name FR","city_name":"city_name FR"}}`,
+	}
+	if len(changes) != len(expect) {
+		t.Fatalf("Invalid changes %v", changes)
+	}
+	for i := range expect {
+		if changes[i] != expect[i] {
+			t.Errorf("Invalid change %d\n%s\n%s", i, changes[i], expect[i])
+		}
+	}
+	data, _ := json.Marshal(d.Stats())
+	if s := string(data); s != `{"added":{"blocks":2,"addresses":79228162514264337593543950592},"removed":{"blocks":1,"addresses":256},"changed":{"blocks":2,"addresses":512},"unchanged":{"blocks":1,"addresses":256},"fields":{"city_name":{"blocks":2,"addresses":512},"country_code":{"blocks":1,"addresses":256},"country_name":{"blocks":1,"addresses":256},"region_name":{"blocks":1,"addresses":256}}}` {
+		t.Errorf("Invalid stats %s", s)
+	}
+
+	d = ip2loc.Diff(old, new, ip2loc.QueryCountryCode)
+	n := 0
+	for c := range d.All() {
+		if c.Kind == ip2loc.Changed && c.Fields != ip2loc.QueryCountryCode {
+			t.Errorf("Compared fields %s", c.Fields)
+		}
+		n++
+	}
+	if n != 4 || d.Stats().Changed.Blocks != 1 {
+		t.Errorf("Invalid changes %d %v", n, d.Stats().Changed)
+	}
+}
@@ -0,0 +1,253 @@
+package ip2location
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SupersededError reports files skipped for a newer file of the same product.
+var SupersededError = errors.New("newer database of the same product loaded")
+
+// ExcludedTypeError reports files skipped because their type is not in DirOptions.Types.
+var ExcludedTypeError = errors.New("database type not selected")
+
+// headerSize is the size of the BIN file header.
+const headerSize = 64
+
+// DirOptions select the files loaded by OpenDir.
+type DirOptions struct {
+	// Mmap memory maps plain files.
+	Mmap bool
+	// Match is a filepath.Match pattern for the base names of database files and archive members.
+	// An empty pattern matches every .bin file.
+	Match string
+	// Types keeps only databases of these types if not empty.
+	// Other files are skipped after reading their header, before they are opened and validated.
+	Types []DBType
+	// SkipInvalid skips files that fail to open or validate and reports them in the manifest instead of failing.
+	SkipInvalid bool
+	// NewestOnly keeps only the latest release of every product,
+	// a product being a database type with or without IPv6 data.
+	NewestOnly bool
+	// Zip loads .bin members of .zip archives, as distributed by IP2Location.
+	Zip bool
+	// Archive controls how compressed zip members are unpacked; its Member pattern is not used.
+	Archive ArchiveOptions
+}
+
+// DirEntry describes a file found by OpenDir.
+type DirEntry struct {
+	Path   string // file path
+	Member string // archive member, empty for plain files
+	Meta   DBMeta
+	Err    error // why the file was skipped
+}
+
+// Manifest lists the files found by OpenDir.
+type Manifest struct {
+	// Loaded is in the order of the returned MultiDB.
+	Loaded  []DirEntry
+	Skipped []DirEntry
+}
+
+func (o *DirOptions) match(name string) bool {
+	if !strings.HasSuffix(strings.ToLower(name), ".bin") {
+		return false
+	}
+	if o.Match == "" {
+		return true
+	}
+	ok, _ := filepath.Match(o.Match, filepath.Base(name))
+	return ok
+}
+
+func (o *DirOptions) hasType(t DBType) bool {
+	if len(o.Types) == 0 {
+		return true
+	}
+	for _, typ := range o.Types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+// dirLoader loads the database files of a directory tree in fsys.
+type dirLoader struct {
+	opts DirOptions
+	fsys fs.FS
+	// path returns the manifest path of a file
+	path func(name string) string
+	// open opens a database file
+	open     func(name string, d fs.DirEntry) (*FileDB, error)
+	dbs      []*FileDB
+	manifest Manifest
+}
+
+// excluded reports whether the header read by open has a type left out of Types
+// and records the file as skipped if so.
+// Files whose header cannot be read are left to fail when opened.
+func (l *dirLoader) excluded(e DirEntry, open func() (io.ReadCloser, error)) bool {
+	if len(l.opts.Types) == 0 {
+		return false
+	}
+	rc, err := open()
+	if err != nil {
+		return false
+	}
+	header := make([]byte, headerSize)
+	_, err = io.ReadFull(rc, header)
+	rc.Close()
+	if err != nil || e.Meta.Read(bytes.NewReader(header)) != nil || l.opts.hasType(e.Meta.Type()) {
+		return false
+	}
+	e.Err = ExcludedTypeError
+	l.manifest.Skipped = append(l.manifest.Skipped, e)
+	return true
+}
+
+// add loads a database opened from e or records why it failed.
+func (l *dirLoader) add(e DirEntry, db *FileDB, err error) error {
+	if err == nil {
+		if err = validate(db.db); err != nil {
+			db.Close()
+		}
+	}
+	if err != nil {
+		if l.opts.SkipInvalid {
+			e.Err = err
+			l.manifest.Skipped = append(l.manifest.Skipped, e)
+			return nil
+		}
+		name := e.Path
+		if e.Member != "" {
+			name += ":" + e.Member
+		}
+		return &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	e.Meta = db.Meta()
+	l.dbs = append(l.dbs, db)
+	l.manifest.Loaded = append(l.manifest.Loaded, e)
+	return nil
+}
+
+func (l *dirLoader) walk(name string, d fs.DirEntry, err error) error {
+	if err != nil {
+		if pe, ok := err.(*fs.PathError); ok {
+			pe.Path = l.path(pe.Path)
+		}
+		return err
+	}
+	if d.IsDir() {
+		return nil
+	}
+	path := l.path(name)
+	switch {
+	case l.opts.match(d.Name()):
+		e := DirEntry{Path: path}
+		if l.excluded(e, func() (io.ReadCloser, error) { return l.fsys.Open(name) }) {
+			return nil
+		}
+		db, err := l.open(name, d)
+		return l.add(e, db, err)
+	case l.opts.Zip && strings.HasSuffix(strings.ToLower(d.Name()), ".zip"):
+		r, size, c, err := openReaderAt(l.fsys, name)
+		if err != nil {
+			return l.add(DirEntry{Path: path}, nil, err)
+		}
+		if c != nil {
+			defer c.Close()
+		}
+		zr, err := zip.NewReader(r, size)
+		if err != nil {
+			return l.add(DirEntry{Path: path}, nil, err)
+		}
+		for _, member := range zr.File {
+			if member.FileInfo().IsDir() || !l.opts.match(member.Name) {
+				continue
+			}
+			e := DirEntry{Path: path, Member: member.Name}
+			if l.excluded(e, member.Open) {
+				continue
+			}
+			db, err := openZipMember(l.fsys, name, member, l.opts.Archive)
+			if err := l.add(e, db, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// newest drops all but the latest release of every product.
+func (l *dirLoader) newest() {
+	type product struct {
+		t    DBType
+		ipv6 bool
+	}
+	latest := map[product]int{}
+	for i := range l.manifest.Loaded {
+		m := &l.manifest.Loaded[i].Meta
+		p := product{m.Type(), m.Has(IPv6)}
+		if j, ok := latest[p]; !ok || m.Date().After(l.manifest.Loaded[j].Meta.Date()) {
+			latest[p] = i
+		}
+	}
+	dbs, loaded := l.dbs[:0], l.manifest.Loaded[:0]
+	for i, e := range l.manifest.Loaded {
+		m := &e.Meta
+		if latest[product{m.Type(), m.Has(IPv6)}] != i {
+			l.dbs[i].Close()
+			e.Err = SupersededError
+			l.manifest.Skipped = append(l.manifest.Skipped, e)
+			continue
+		}
+		dbs = append(dbs, l.dbs[i])
+		loaded = append(loaded, e)
+	}
+	l.dbs, l.manifest.Loaded = dbs, loaded
+}
+
+// OpenDir loads the database files below path in lexical order.
+// The manifest lists the loaded files and, with SkipInvalid, Types or NewestOnly, the skipped ones.
+func OpenDir(path string, opts DirOptions) (MultiDB, *Manifest, error) {
+	l := dirLoader{
+		opts: opts,
+		fsys: os.DirFS(path),
+		path: func(name string) string {
+			return filepath.Join(path, filepath.FromSlash(name))
+		},
+	}
+	l.open = func(name string, d fs.DirEntry) (*FileDB, error) {
+		info, err := d.Info()
+		if err != nil {
+			return nil, err
+		}
+		return openFileDB(l.path(name), info.Size(), opts.Mmap)
+	}
+	return l.load(".")
+}
+
+func (l *dirLoader) load(root string) (MultiDB, *Manifest, error) {
+	if err := fs.WalkDir(l.fsys, root, l.walk); err != nil {
+		for _, db := range l.dbs {
+			db.Close()
+		}
+		return nil, nil, err
+	}
+	if l.opts.NewestOnly {
+		l.newest()
+	}
+	dbs := make(MultiDB, len(l.dbs))
+	for i, db := range l.dbs {
+		dbs[i] = db
+	}
+	return dbs, &l.manifest, nil
+}
@@ -0,0 +1,115 @@
+package ip2location_test
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ip2loc "github.com/alxarch/ip2location-go"
+)
+
+func writeZip(t *testing.T, path string, data []byte, method uint16) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "IP2LOCATION/DB.BIN", Method: method})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_OpenDir(t *testing.T) {
+	data, err := os.ReadFile(binfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{
+		"a.bin":       data,
+		"sub/b.BIN":   data,
+		"corrupt.bin": data[:len(data)/2],
+		"empty.bin":   nil,
+		"notes.txt":   []byte("not a database"),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeZip(t, filepath.Join(dir, "stored.zip"), data, zip.Store)
+	writeZip(t, filepath.Join(dir, "deflated.zip"), data, zip.Deflate)
+
+	if _, err := ip2loc.NewDirDB(dir, false); err == nil {
+		t.Error("Loaded directory with corrupt files")
+	}
+
+	db, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	meta := db.Meta()
+	other := ip2loc.DB1
+	if meta.Type() == other {
+		other = ip2loc.DB2
+	}
+	for _, tc := range []struct {
+		opts    ip2loc.DirOptions
+		loaded  []string
+		skipped int
+	}{
+		{ip2loc.DirOptions{SkipInvalid: true}, []string{"a.bin", "sub/b.BIN"}, 2},
+		{ip2loc.DirOptions{SkipInvalid: true, Mmap: true, Zip: true}, []string{"a.bin", "deflated.zip", "stored.zip", "sub/b.BIN"}, 2},
+		{ip2loc.DirOptions{SkipInvalid: true, Zip: true, NewestOnly: true}, []string{"a.bin"}, 5},
+		{ip2loc.DirOptions{Match: "?.bin"}, []string{"a.bin"}, 0},
+		{ip2loc.DirOptions{Match: "*.BIN", Zip: true}, []string{"deflated.zip", "stored.zip", "sub/b.BIN"}, 0},
+		{ip2loc.DirOptions{Match: "[ab].*", Types: []ip2loc.DBType{other}}, nil, 2},
+		// files of other types are skipped before validation
+		{ip2loc.DirOptions{Match: "[abcD]*", Zip: true, Types: []ip2loc.DBType{other}}, nil, 5},
+		{ip2loc.DirOptions{Match: "[ab].*", Types: []ip2loc.DBType{other, meta.Type()}}, []string{"a.bin", "sub/b.BIN"}, 0},
+	} {
+		dbs, manifest, err := ip2loc.OpenDir(dir, tc.opts)
+		if err != nil {
+			t.Errorf("%+v: %s", tc.opts, err)
+			continue
+		}
+		if len(dbs) != len(tc.loaded) || len(manifest.Loaded) != len(tc.loaded) || len(manifest.Skipped) != tc.skipped {
+			t.Errorf("%+v: loaded %+v skipped %+v", tc.opts, manifest.Loaded, manifest.Skipped)
+		}
+		for i, e := range manifest.Loaded {
+			if i < len(tc.loaded) && e.Path != filepath.Join(dir, tc.loaded[i]) {
+				t.Errorf("%+v: loaded %s, expected %s", tc.opts, e.Path, tc.loaded[i])
+			}
+			if e.Meta.Type() != meta.Type() {
+				t.Errorf("%+v: invalid meta %+v", tc.opts, e.Meta)
+			}
+			x := ip2loc.Record{}
+			if err := dbs[i].Query("8.8.8.8", &x, ip2loc.QueryAll); err != nil && !errors.Is(err, ip2loc.NoMatchError) {
+				t.Errorf("%+v: query %s", tc.opts, err)
+			}
+		}
+		for _, e := range manifest.Skipped {
+			if e.Err == nil {
+				t.Errorf("%+v: skipped %s without error", tc.opts, e.Path)
+			}
+			if len(tc.opts.Types) != 0 && (!errors.Is(e.Err, ip2loc.ExcludedTypeError) || e.Meta.Type() != meta.Type()) {
+				t.Errorf("%+v: skipped %s with %v %+v", tc.opts, e.Path, e.Err, e.Meta)
+			}
+		}
+		if err := dbs.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
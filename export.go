@@ -0,0 +1,114 @@
+package ip2location
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// AddressNotation selects how Export writes the addresses of a block.
+type AddressNotation int
+
+const (
+	// NumericAddresses writes ip_from and ip_to IP numbers, as in IP2Location CSV files.
+	NumericAddresses AddressNotation = iota
+	// TextAddresses writes the first and last address of a block.
+	TextAddresses
+	// CIDRAddresses writes a row for every prefix of a block with a single cidr column.
+	CIDRAddresses
+)
+
+// ExportOptions control Export.
+type ExportOptions struct {
+	// Mode selects the fields following the addresses; zero selects every field of the database.
+	// Fields the database does not have are left out.
+	Mode QueryMode
+	// Notation of the addresses.
+	Notation AddressNotation
+	// Type exports only the table of Type; zero exports the IPv4 table followed by the IPv6 table.
+	Type IPType
+	// Header writes a row of column names first. IP2Location CSV files have no header.
+	Header bool
+}
+
+// Export writes the blocks of db in the IP2Location CSV layout, every value quoted.
+// Columns follow the CSV order of the fields, which is the column order of the database type.
+// Rows are streamed from the tables as they are read; addresses without a match are not written.
+func Export(w io.Writer, db *DB, opts ExportOptions) error {
+	mode := opts.Mode & QueryAll & db.mode
+	if opts.Mode == 0 {
+		mode = db.mode
+	}
+	if mode == 0 {
+		return NotSupportedError
+	}
+	if opts.Notation < NumericAddresses || opts.Notation > CIDRAddresses {
+		return fmt.Errorf("unknown address notation %d", opts.Notation)
+	}
+	fields := mode.Fields()
+	bw := bufio.NewWriter(w)
+	var row []string
+	if opts.Header {
+		if opts.Notation == CIDRAddresses {
+			row = append(row, "cidr")
+		} else {
+			row = append(row, "ip_from", "ip_to")
+		}
+		for _, m := range fields {
+			row = append(row, m.Name())
+		}
+		if err := writeCSVRow(bw, row); err != nil {
+			return err
+		}
+	}
+	types := []IPType{IPv4, IPv6}
+	if opts.Type != 0 {
+		types = []IPType{opts.Type}
+	}
+	for _, t := range types {
+		if !db.meta.Has(t) {
+			continue
+		}
+		c := db.Ranges(t, mode)
+		for r, x := range c.All() {
+			switch opts.Notation {
+			case NumericAddresses:
+				row = append(row[:0], r.From.String(), r.last().String())
+			case TextAddresses:
+				row = append(row[:0], r.Start().String(), r.End().String())
+			case CIDRAddresses:
+				for _, p := range r.Prefixes() {
+					row = append(row[:0], p.String())
+					row = x.appendFields(row, fields)
+					if err := writeCSVRow(bw, row); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			row = x.appendFields(row, fields)
+			if err := writeCSVRow(bw, row); err != nil {
+				return err
+			}
+		}
+		if err := c.Err(); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// writeCSVRow writes a row with every value quoted, as in IP2Location CSV files.
+func writeCSVRow(w *bufio.Writer, row []string) error {
+	for i, v := range row {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteByte('"')
+		w.WriteString(strings.ReplaceAll(v, `"`, `""`))
+		w.WriteByte('"')
+	}
+	_, err := w.WriteString("\n")
+	return err
+}
@@ -0,0 +1,86 @@
+package ip2location_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	ip2loc "github.com/alxarch/ip2location-go"
+	"github.com/alxarch/ip2location-go/ip2locationtest"
+	"github.com/alxarch/ip2location-go/ip2locationwriter"
+)
+
+func Test_Export(t *testing.T) {
+	db := ip2locationtest.New(t, ip2locationtest.Spec{
+		Type: ip2loc.DB5,
+		Blocks: []ip2locationtest.Block{
+			{Range: "1.0.0.0-1.0.2.255", Record: ip2locationtest.Record(ip2loc.DB5.Modes(), "GR")},
+			{Range: "255.255.255.0/24", Record: ip2locationtest.Record(ip2loc.DB5.Modes(), "US")},
+			{Range: "2001:db8::/32", Record: ip2locationtest.Record(ip2loc.DB5.Modes(), "FR")},
+		},
+	})
+	buf := bytes.Buffer{}
+	if err := ip2loc.Export(&buf, db, ip2loc.ExportOptions{Type: ip2loc.IPv4}); err != nil {
+		t.Fatal(err)
+	}
+	expect := `"16777216","16777983","GR","country_name GR","region_name GR","city_name GR","15.125","-30.25"
+"4294967040","4294967295","US","country_name US","region_name US","city_name US","43.1875","-86.375"
+`
+	if buf.String() != expect {
+		t.Errorf("Invalid export\n%s", buf.String())
+	}
+
+	// numeric exports of every table read back into the same database
+	for _, typ := range []ip2loc.IPType{ip2loc.IPv4, ip2loc.IPv6} {
+		buf.Reset()
+		if err := ip2loc.Export(&buf, db, ip2loc.ExportOptions{Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+		w, _ := ip2locationwriter.New(ip2loc.DB5, ip2locationtest.DefaultDate)
+		if err := w.ReadCSV(&buf, typ); err != nil {
+			t.Fatal(err)
+		}
+		buf.Reset()
+		if _, err := w.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		cp, err := ip2loc.NewDB(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ip := range []string{"1.0.0.0", "1.0.2.255", "1.0.3.0", "255.255.255.255", "2001:db8::1", "::1"} {
+			if (typ == ip2loc.IPv4) != strings.Contains(ip, ".") {
+				continue
+			}
+			x, y := ip2loc.Record{}, ip2loc.Record{}
+			err := db.Query(ip, &x, ip2loc.QueryAll)
+			if cperr := cp.Query(ip, &y, ip2loc.QueryAll); (err == nil) != (cperr == nil) || x != y {
+				t.Errorf("%s: %v %v != %v %v", ip, y, cperr, x, err)
+			}
+		}
+	}
+
+	buf.Reset()
+	opts := ip2loc.ExportOptions{Mode: ip2loc.QueryCountryCode | ip2loc.QueryISP, Notation: ip2loc.CIDRAddresses, Header: true}
+	if err := ip2loc.Export(&buf, db, opts); err != nil {
+		t.Fatal(err)
+	}
+	expect = `"cidr","country_code"
+"1.0.0.0/23","GR"
+"1.0.2.0/24","GR"
+"255.255.255.0/24","US"
+"2001:db8::/32","FR"
+`
+	if buf.String() != expect {
+		t.Errorf("Invalid CIDR export\n%s", buf.String())
+	}
+
+	buf.Reset()
+	opts = ip2loc.ExportOptions{Mode: ip2loc.QueryCity, Notation: ip2loc.TextAddresses, Type: ip2loc.IPv6}
+	if err := ip2loc.Export(&buf, db, opts); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != "\"2001:db8::\",\"2001:db8:ffff:ffff:ffff:ffff:ffff:ffff\",\"city_name FR\"\n" {
+		t.Errorf("Invalid text export %s", s)
+	}
+}
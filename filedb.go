@@ -2,56 +2,121 @@ package ip2location
 
 import (
 	"bytes"
+	"context"
 	"io"
-	"io/ioutil"
+	"net/netip"
 	"os"
-	"strings"
-	"syscall"
+	"sync"
 )
 
+// FileDB is a database read from a file, optionally memory mapped.
+// It owns the file and mapping until Close; queries after Close fail with DBClosedError.
+// It is safe for concurrent use and Close waits for running queries to finish.
 type FileDB struct {
-	f  *os.File
-	db *DB
+	mu     sync.RWMutex
+	f      io.Closer
+	data   []byte // memory mapped file contents
+	db     *DB
+	closed bool
 }
 
 func (fd *FileDB) Query(ip string, r *Record, mode QueryMode) error {
+	fd.mu.RLock()
+	defer fd.mu.RUnlock()
+	if fd.closed {
+		return fd.db.queryError(ip, mode, DBClosedError)
+	}
 	return fd.db.Query(ip, r, mode)
 }
-func (fdb *FileDB) Close() {
-	if nil != fdb.f {
-		fdb.f.Close()
+func (fd *FileDB) QueryContext(ctx context.Context, ip string, r *Record, mode QueryMode) error {
+	fd.mu.RLock()
+	defer fd.mu.RUnlock()
+	if fd.closed {
+		return fd.db.queryError(ip, mode, DBClosedError)
+	}
+	return fd.db.QueryContext(ctx, ip, r, mode)
+}
+func (fd *FileDB) QueryAddr(ip netip.Addr, r *Record, mode QueryMode) error {
+	fd.mu.RLock()
+	defer fd.mu.RUnlock()
+	if fd.closed {
+		return fd.db.queryError(ip.String(), mode, DBClosedError)
 	}
-	if nil != fdb.db {
-		fdb.db.Close()
+	return fd.db.QueryAddr(ip, r, mode)
+}
+func (fd *FileDB) QueryRange(ip string, r *Record, mode QueryMode) (Range, error) {
+	fd.mu.RLock()
+	defer fd.mu.RUnlock()
+	if fd.closed {
+		return Range{}, fd.db.queryError(ip, mode, DBClosedError)
+	}
+	return fd.db.QueryRange(ip, r, mode)
+}
+func (fd *FileDB) QueryRangeContext(ctx context.Context, ip string, r *Record, mode QueryMode) (Range, error) {
+	fd.mu.RLock()
+	defer fd.mu.RUnlock()
+	if fd.closed {
+		return Range{}, fd.db.queryError(ip, mode, DBClosedError)
 	}
+	return fd.db.QueryRangeContext(ctx, ip, r, mode)
+}
+func (fd *FileDB) QueryAddrRange(ip netip.Addr, r *Record, mode QueryMode) (Range, error) {
+	fd.mu.RLock()
+	defer fd.mu.RUnlock()
+	if fd.closed {
+		return Range{}, fd.db.queryError(ip.String(), mode, DBClosedError)
+	}
+	return fd.db.QueryAddrRange(ip, r, mode)
+}
+
+// Meta returns the header of the database.
+func (fd *FileDB) Meta() DBMeta {
+	return fd.db.Meta()
+}
 
+// SupportedModes returns the fields of the database.
+func (fd *FileDB) SupportedModes() QueryMode {
+	return fd.db.SupportedModes()
 }
 
+// Close waits for running queries, then unmaps and closes the file.
+// Closing more than once is a no-op.
+func (fd *FileDB) Close() error {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	if fd.closed {
+		return nil
+	}
+	fd.closed = true
+	return fd.release()
+}
+
+// release unmaps and closes the file.
+func (fd *FileDB) release() (err error) {
+	if fd.data != nil {
+		err = munmap(fd.data)
+		fd.data = nil
+	}
+	if fd.f != nil {
+		if e := fd.f.Close(); err == nil {
+			err = e
+		}
+		fd.f = nil
+	}
+	return
+}
+
+// NewDirDB loads every .bin file below path and fails if any of them is invalid.
+// Use OpenDir for more control.
 func NewDirDB(path string, mmap bool) (IP2LocationDB, error) {
-	entries, err := ioutil.ReadDir(path)
+	dbs, _, err := OpenDir(path, DirOptions{Mmap: mmap})
 	if err != nil {
 		return nil, err
 	}
-	dbs := MultiDB{}
-	for _, entry := range entries {
-		p := path + string(os.PathSeparator) + entry.Name()
-		if entry.IsDir() {
-			if db, err := NewDirDB(p, mmap); err != nil {
-				return nil, err
-			} else {
-				dbs = append(dbs, db)
-			}
-		}
-		if strings.HasSuffix(strings.ToLower(entry.Name()), ".bin") {
-			if db, err := NewFileDB(p, mmap); err != nil {
-				return nil, err
-			} else {
-				dbs = append(dbs, db)
-			}
-		}
-	}
 	return dbs, nil
 }
+
+// NewFileDB opens a database file, a directory of them like NewDirDB or an archive like OpenArchive.
 func NewFileDB(path string, mmap bool) (IP2LocationDB, error) {
 	var err error
 	s, err := os.Stat(path)
@@ -61,31 +126,39 @@ func NewFileDB(path string, mmap bool) (IP2LocationDB, error) {
 	if s.IsDir() {
 		return NewDirDB(path, mmap)
 	}
+	var db *FileDB
+	if IsArchive(path) {
+		db, err = OpenArchive(path, ArchiveOptions{})
+	} else {
+		db, err = openFileDB(path, s.Size(), mmap)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
 
-	db := &FileDB{}
-	var r io.ReaderAt
+func openFileDB(path string, size int64, mmap bool) (*FileDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	db := &FileDB{f: f}
+	var r io.ReaderAt = f
 	if mmap {
-		var fd int
-		if fd, err = syscall.Open(path, syscall.O_RDONLY, 0); err != nil {
-			return nil, err
+		if size <= 0 {
+			db.release()
+			return nil, MissingFileError
 		}
-		var data []byte
-		if data, err = syscall.Mmap(fd, 0, int(s.Size()), syscall.PROT_READ, syscall.MAP_SHARED); err != nil {
-			return nil, err
-		}
-		r = bytes.NewReader(data)
-	} else {
-		if db.f, err = os.Open(path); err != nil {
+		if db.data, err = mmapFile(f, size); err != nil {
+			db.release()
 			return nil, err
 		}
-		r = db.f
+		r = bytes.NewReader(db.data)
 	}
-	if r != nil {
-		if db.db, err = NewDB(r); err != nil {
-			return nil, err
-		}
+	if db.db, err = NewDB(r); err != nil {
+		db.release()
+		return nil, err
 	}
-
 	return db, nil
-
 }
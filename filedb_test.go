@@ -0,0 +1,85 @@
+package ip2location_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ip2loc "github.com/alxarch/ip2location-go"
+)
+
+func openFDs(t *testing.T) int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("Cannot count open files", err)
+	}
+	return len(entries)
+}
+
+func mappings(t *testing.T, path string) int {
+	data, err := os.ReadFile("/proc/self/maps")
+	if err != nil {
+		t.Skip("Cannot read memory mappings", err)
+	}
+	return strings.Count(string(data), path)
+}
+
+func Test_FileDBClose(t *testing.T) {
+	path, err := filepath.Abs(binfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mmap := range []bool{true, false} {
+		fds := openFDs(t)
+		for i := 0; i < 2000; i++ {
+			db, err := ip2loc.NewFileDB(path, mmap)
+			if err != nil {
+				t.Fatalf("Failed to open db %s", err)
+			}
+			if err := db.Close(); err != nil {
+				t.Fatalf("Failed to close db %s", err)
+			}
+			if err := db.Close(); err != nil {
+				t.Fatalf("Failed to close db twice %s", err)
+			}
+		}
+		if n := openFDs(t); n > fds {
+			t.Errorf("Leaked %d file descriptors (mmap %v)", n-fds, mmap)
+		}
+		if n := mappings(t, path); n > 0 {
+			t.Errorf("Leaked %d memory mappings", n)
+		}
+	}
+}
+
+func Test_FileDBQueryAfterClose(t *testing.T) {
+	for _, mmap := range []bool{true, false} {
+		db, err := ip2loc.NewFileDB(binfile, mmap)
+		if err != nil {
+			t.Fatalf("Failed to open db %s", err)
+		}
+		db.Close()
+		var qe *ip2loc.QueryError
+		if err := db.Query("8.8.8.8", &ip2loc.Record{}, ip2loc.QueryCountryCode); !errors.As(err, &qe) || !errors.Is(err, ip2loc.DBClosedError) || qe.IP != "8.8.8.8" {
+			t.Errorf("Query after close %v", err)
+		}
+		if err := db.QueryContext(context.Background(), "8.8.8.8", &ip2loc.Record{}, ip2loc.QueryCountryCode); !errors.As(err, &qe) || !errors.Is(err, ip2loc.DBClosedError) || qe.Type == 0 {
+			t.Errorf("Query with context after close %v", err)
+		}
+	}
+}
+
+func Test_FileDBOpenInvalid(t *testing.T) {
+	fds := openFDs(t)
+	for i := 0; i < 100; i++ {
+		if _, err := ip2loc.NewFileDB("filedb_test.go", true); err == nil {
+			t.Fatal("Opened invalid database")
+		}
+	}
+	if n := openFDs(t); n > fds {
+		t.Errorf("Leaked %d file descriptors", n-fds)
+	}
+}
@@ -0,0 +1,74 @@
+package ip2location
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+)
+
+// openReaderAt opens name for random access.
+// Files that do not implement io.ReaderAt are read into memory and closed, returning a nil Closer.
+func openReaderAt(fsys fs.FS, name string) (io.ReaderAt, int64, io.Closer, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, 0, nil, &fs.PathError{Op: "open", Path: name, Err: MissingFileError}
+	}
+	if r, ok := f.(io.ReaderAt); ok {
+		return r, info.Size(), f, nil
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return bytes.NewReader(data), int64(len(data)), nil, nil
+}
+
+// OpenFS opens the database file name in fsys.
+// Files that implement io.ReaderAt, like those of embed.FS, fstest.MapFS and os.DirFS, are read in place;
+// other files are read into memory.
+func OpenFS(fsys fs.FS, name string) (*FileDB, error) {
+	r, _, c, err := openReaderAt(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	db := &FileDB{f: c}
+	if db.db, err = NewDB(r); err != nil {
+		db.release()
+		return nil, err
+	}
+	return db, nil
+}
+
+// NewFSDirDB loads every .bin file below dir in fsys and fails if any of them is invalid.
+func NewFSDirDB(fsys fs.FS, dir string) (IP2LocationDB, error) {
+	dbs, _, err := OpenFSDir(fsys, dir, DirOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return dbs, nil
+}
+
+// OpenFSDir is like OpenDir for a directory in fsys. The Mmap option is ignored.
+func OpenFSDir(fsys fs.FS, dir string, opts DirOptions) (MultiDB, *Manifest, error) {
+	l := dirLoader{
+		opts: opts,
+		fsys: fsys,
+		path: func(name string) string {
+			return name
+		},
+		open: func(name string, _ fs.DirEntry) (*FileDB, error) {
+			return OpenFS(fsys, name)
+		},
+	}
+	return l.load(dir)
+}
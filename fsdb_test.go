@@ -0,0 +1,99 @@
+package ip2location_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	ip2loc "github.com/alxarch/ip2location-go"
+)
+
+// streamFS hides io.ReaderAt from the files of an fs.FS.
+type streamFS struct {
+	fs.FS
+}
+
+type streamFile struct {
+	fs.File
+}
+
+func (s streamFS) Open(name string) (fs.File, error) {
+	f, err := s.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := f.(fs.ReadDirFile); ok {
+		return f, nil
+	}
+	return streamFile{f}, nil
+}
+
+func Test_OpenFS(t *testing.T) {
+	data, err := os.ReadFile(binfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipped := bytes.Buffer{}
+	zw := zip.NewWriter(&zipped)
+	for _, method := range []uint16{zip.Store, zip.Deflate} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: "DB.BIN", Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mapfs := fstest.MapFS{
+		"data/db.bin":      {Data: data},
+		"data/db.zip":      {Data: zipped.Bytes()},
+		"data/corrupt.bin": {Data: data[:len(data)/2]},
+		"other/readme.txt": {Data: []byte("readme")},
+	}
+	ref, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	expect := ip2loc.Record{}
+	ref.Query("8.8.8.8", &expect, ip2loc.QueryAll)
+
+	for _, fsys := range []fs.FS{mapfs, streamFS{mapfs}} {
+		db, err := ip2loc.OpenFS(fsys, "data/db.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		x := ip2loc.Record{}
+		if db.Query("8.8.8.8", &x, ip2loc.QueryAll); x != expect {
+			t.Errorf("%T: query %+v", fsys, x)
+		}
+		if err := db.Close(); err != nil {
+			t.Error(err)
+		}
+		if _, err := ip2loc.OpenFS(fsys, "data"); !errors.Is(err, ip2loc.MissingFileError) {
+			t.Errorf("%T: opened directory %v", fsys, err)
+		}
+		if _, err := ip2loc.NewFSDirDB(fsys, "."); err == nil {
+			t.Errorf("%T: loaded corrupt file", fsys)
+		}
+		dbs, manifest, err := ip2loc.OpenFSDir(fsys, "data", ip2loc.DirOptions{SkipInvalid: true, Zip: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dbs) != 3 || len(manifest.Skipped) != 1 || manifest.Skipped[0].Path != "data/corrupt.bin" {
+			t.Errorf("%T: loaded %+v skipped %+v", fsys, manifest.Loaded, manifest.Skipped)
+		}
+		for _, db := range dbs {
+			x := ip2loc.Record{}
+			db.Query("8.8.8.8", &x, ip2loc.QueryAll)
+			if x != expect {
+				t.Errorf("%T: query %+v", fsys, x)
+			}
+		}
+		dbs.Close()
+	}
+}
@@ -1,35 +1,33 @@
 package ip2location
 
 import (
-	"bytes"
 	"encoding/binary"
 	"io"
-	"math/big"
+	"math"
 	"sync"
 )
 
+// Scratch buffers are pooled as array pointers so that Get/Put do not allocate.
+// A string length is a single byte so 256 bytes fit any column value.
 var bpool = sync.Pool{
 	New: func() interface{} {
-		return make([]byte, 16)
+		return new([256]byte)
 	},
 }
 
-func blank(size int) []byte {
-	if b, ok := bpool.Get().([]byte); ok && size <= cap(b) {
-		return b[:size]
-	}
-	return make([]byte, size)
+func blank() *[256]byte {
+	return bpool.Get().(*[256]byte)
 }
 
-func release(b []byte) {
-	bpool.Put(b[:cap(b)])
+func release(b *[256]byte) {
+	bpool.Put(b)
 }
 
 // read byte
 func readUint8(r io.ReaderAt, pos int64) (uint8, error) {
-	data := blank(1)
+	data := blank()
 	defer release(data)
-	if _, err := r.ReadAt(data, pos-1); err != nil {
+	if _, err := r.ReadAt(data[:1], pos-1); err != nil {
 		return 0, err
 	}
 	return data[0], nil
@@ -37,62 +35,46 @@ func readUint8(r io.ReaderAt, pos int64) (uint8, error) {
 
 // read unsigned 32-bit integer
 func readUint32(r io.ReaderAt, pos uint32) (uint32, error) {
-	data := blank(4)
+	data := blank()
 	defer release(data)
-	if _, err := r.ReadAt(data, int64(pos-1)); err != nil {
-		return 0, err
-	}
-	var retval uint32
-	buf := bytes.NewReader(data)
-	if err := binary.Read(buf, binary.LittleEndian, &retval); err != nil {
+	if _, err := r.ReadAt(data[:4], int64(pos)-1); err != nil {
 		return 0, err
 	}
-	return retval, nil
+	return binary.LittleEndian.Uint32(data[:4]), nil
 }
 
 // read unsigned 128-bit integer
-func readUint128(r io.ReaderAt, pos uint32) (*big.Int, error) {
-	data := blank(16)
+func readUint128(r io.ReaderAt, pos uint32) (Uint128, error) {
+	data := blank()
 	defer release(data)
-	if _, err := r.ReadAt(data, int64(pos-1)); err != nil {
-		return nil, err
-	}
-	retval := big.NewInt(0)
-
-	// little endian to big endian
-	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
-		data[i], data[j] = data[j], data[i]
+	if _, err := r.ReadAt(data[:16], int64(pos)-1); err != nil {
+		return Uint128{}, err
 	}
-	retval.SetBytes(data)
-	return retval, nil
+	return Uint128{
+		Hi: binary.LittleEndian.Uint64(data[8:16]),
+		Lo: binary.LittleEndian.Uint64(data[0:8]),
+	}, nil
 }
 
 // read string
 func readString(r io.ReaderAt, pos uint32) (string, error) {
-	var s string
-	lenbyte := blank(1)
-	defer release(lenbyte)
-	if _, err := r.ReadAt(lenbyte, int64(pos)); err != nil {
-		return s, err
+	data := blank()
+	defer release(data)
+	if _, err := r.ReadAt(data[:1], int64(pos)); err != nil {
+		return "", err
 	}
-	strlen := lenbyte[0]
-	data := make([]byte, strlen)
-	if _, err := r.ReadAt(data, int64(pos)+1); err != nil {
-		return s, err
+	strlen := int(data[0])
+	if _, err := r.ReadAt(data[:strlen], int64(pos)+1); err != nil {
+		return "", err
 	}
 	return string(data[:strlen]), nil
 }
 
 // read float
-func rFloat(r io.ReaderAt, pos uint32) (float32, error) {
-	var f float32
-	data := make([]byte, 4)
-	if _, err := r.ReadAt(data, int64(pos)-1); err != nil {
-		return 0.0, err
-	}
-	buf := bytes.NewReader(data)
-	if err := binary.Read(buf, binary.LittleEndian, &f); err != nil {
-		return .0, err
+func readFloat(r io.ReaderAt, pos uint32) (float32, error) {
+	n, err := readUint32(r, pos)
+	if err != nil {
+		return 0, err
 	}
-	return f, nil
+	return math.Float32frombits(n), nil
 }
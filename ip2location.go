@@ -3,7 +3,6 @@ package ip2location
 import (
 	"bytes"
 	"io/ioutil"
-	"math/big"
 )
 
 const api_version string = "8.0.3"
@@ -22,8 +21,6 @@ func OpenDB(dbpath string) (*DB, error) {
 	return NewDB(bytes.NewReader(data))
 }
 
-var bigOne = big.NewInt(1)
-
 type IPType int
 
 const (
@@ -0,0 +1,201 @@
+// Package ip2locationhttp serves IP2Location lookups over HTTP.
+package ip2locationhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	ip2location "github.com/alxarch/ip2location-go"
+)
+
+// DefaultMaxBatch limits the number of addresses in a batch lookup.
+const DefaultMaxBatch = 1000
+
+// batchAddressSize is the request body size allowed per address of a batch lookup,
+// enough for the longest address with its quotes, a separator and some whitespace.
+const batchAddressSize = 64
+
+// Handler exposes a database as a JSON API:
+//
+//	GET  /lookup/{ip}?fields=city,isp   look up one address
+//	POST /lookup?fields=city,isp        look up a JSON array of addresses
+//	GET  /meta                          describe the loaded databases
+//
+// Fields default to all fields.
+type Handler struct {
+	DB       ip2location.IP2LocationDB
+	MaxBatch int
+	mux      *http.ServeMux
+}
+
+// NewHandler creates a Handler for db.
+func NewHandler(db ip2location.IP2LocationDB) *Handler {
+	h := &Handler{DB: db, MaxBatch: DefaultMaxBatch}
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("GET /lookup/{ip}", h.lookup)
+	h.mux.HandleFunc("POST /lookup", h.batch)
+	h.mux.HandleFunc("GET /meta", h.meta)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// StatusCode maps query errors to HTTP status codes.
+func StatusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ip2location.InvalidAddressError):
+		return http.StatusBadRequest
+	case errors.Is(err, ip2location.NoMatchError):
+		return http.StatusNotFound
+	case errors.Is(err, ip2location.UnsupportedAddressTypeError):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ip2location.NotSupportedError):
+		return http.StatusNotImplemented
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{err.Error()})
+}
+
+func queryMode(r *http.Request) (ip2location.QueryMode, error) {
+	fields := r.URL.Query().Get("fields")
+	if fields == "" {
+		return ip2location.QueryAll, nil
+	}
+	return ip2location.ParseQueryMode(fields)
+}
+
+// result is a looked up address with the populated fields of its record, or the error of its query.
+type result struct {
+	IP     string
+	Record *ip2location.Record
+	Err    error
+}
+
+// MarshalJSON writes the address followed by the fields of the record, as Record.MarshalJSONWithIP does,
+// or by the error and its status code.
+func (r *result) MarshalJSON() ([]byte, error) {
+	if r.Err == nil {
+		return r.Record.MarshalJSONWithIP(r.IP)
+	}
+	ip, err := json.Marshal(r.IP)
+	if err != nil {
+		return nil, err
+	}
+	b := bytes.Buffer{}
+	b.WriteString(`{"ip":`)
+	b.Write(ip)
+	msg, err := json.Marshal(r.Err.Error())
+	if err != nil {
+		return nil, err
+	}
+	b.WriteString(`,"error":`)
+	b.Write(msg)
+	fmt.Fprintf(&b, `,"status":%d}`, StatusCode(r.Err))
+	return b.Bytes(), nil
+}
+
+func (h *Handler) lookup(w http.ResponseWriter, r *http.Request) {
+	mode, err := queryMode(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ip := r.PathValue("ip")
+	x := ip2location.Record{}
+	if err := h.DB.QueryContext(r.Context(), ip, &x, mode); err != nil {
+		writeError(w, StatusCode(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &result{IP: ip, Record: &x})
+}
+
+func (h *Handler) batch(w http.ResponseWriter, r *http.Request) {
+	mode, err := queryMode(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	max := h.MaxBatch
+	if max > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(max+1)*batchAddressSize)
+	}
+	var ips []string
+	if err := json.NewDecoder(r.Body).Decode(&ips); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, errors.New("too many addresses"))
+			return
+		}
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if max > 0 && len(ips) > max {
+		writeError(w, http.StatusRequestEntityTooLarge, errors.New("too many addresses"))
+		return
+	}
+	results := make([]*result, 0, len(ips))
+	for _, ip := range ips {
+		x := ip2location.Record{}
+		err := h.DB.QueryContext(r.Context(), ip, &x, mode)
+		if ctxErr := r.Context().Err(); ctxErr != nil {
+			writeError(w, StatusCode(ctxErr), ctxErr)
+			return
+		}
+		results = append(results, &result{IP: ip, Record: &x, Err: err})
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// metaDB is implemented by databases that expose their header.
+type metaDB interface {
+	Meta() ip2location.DBMeta
+}
+
+// metas returns the headers of db, or of its databases for a MultiDB or MergeDB.
+func metas(db ip2location.IP2LocationDB) []ip2location.DBMeta {
+	switch db := db.(type) {
+	case metaDB:
+		return []ip2location.DBMeta{db.Meta()}
+	case ip2location.MultiDB:
+		var m []ip2location.DBMeta
+		for _, db := range db {
+			m = append(m, metas(db)...)
+		}
+		return m
+	case *ip2location.MergeDB:
+		return metas(db.MultiDB)
+	}
+	return nil
+}
+
+func (h *Handler) meta(w http.ResponseWriter, r *http.Request) {
+	m := metas(h.DB)
+	if m == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("database does not describe its header"))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"databases": m})
+}
@@ -0,0 +1,119 @@
+package ip2locationhttp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ip2location "github.com/alxarch/ip2location-go"
+	"github.com/alxarch/ip2location-go/ip2locationhttp"
+)
+
+// stubDB answers from a map and fails with NoMatchError otherwise.
+// Records are populated with the requested fields of their Mode.
+type stubDB map[string]ip2location.Record
+
+func (db stubDB) Query(ip string, x *ip2location.Record, mode ip2location.QueryMode) error {
+	return db.QueryContext(context.Background(), ip, x, mode)
+}
+
+func (db stubDB) QueryContext(ctx context.Context, ip string, x *ip2location.Record, mode ip2location.QueryMode) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	switch ip {
+	case "invalid":
+		return ip2location.InvalidAddressError
+	case "::1":
+		return ip2location.UnsupportedAddressTypeError
+	}
+	if mode&^ip2location.QueryISP == 0 {
+		return ip2location.NotSupportedError
+	}
+	r, ok := db[ip]
+	if !ok {
+		return ip2location.NoMatchError
+	}
+	*x = r
+	x.Mode &= mode
+	return nil
+}
+
+func (db stubDB) Close() error {
+	return nil
+}
+
+func Test_Handler(t *testing.T) {
+	db := stubDB{"8.8.8.8": {CountryCode: "US", City: "Mountain View", Mode: ip2location.QueryCountryCode | ip2location.QueryCity}}
+	srv := httptest.NewServer(ip2locationhttp.NewHandler(db))
+	defer srv.Close()
+
+	for path, status := range map[string]int{
+		"/lookup/8.8.8.8":             http.StatusOK,
+		"/lookup/8.8.8.8?fields=city": http.StatusOK,
+		"/lookup/8.8.8.8?fields=nope": http.StatusBadRequest,
+		"/lookup/8.8.8.8?fields=isp":  http.StatusNotImplemented,
+		"/lookup/invalid":             http.StatusBadRequest,
+		"/lookup/1.1.1.1":             http.StatusNotFound,
+		"/lookup/::1":                 http.StatusUnprocessableEntity,
+		"/meta":                       http.StatusNotImplemented,
+	} {
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != status {
+			t.Errorf("GET %s: status %d, expected %d", path, res.StatusCode, status)
+		}
+	}
+
+	// fields the record does not populate are left out
+	res, err := http.Get(srv.URL + "/lookup/8.8.8.8?fields=city,region_name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := map[string]interface{}{}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != 2 || body["ip"] != "8.8.8.8" || body["city_name"] != "Mountain View" {
+		t.Errorf("Invalid lookup response %v", body)
+	}
+
+	res, err = http.Post(srv.URL+"/lookup?fields=country_code", "application/json", strings.NewReader(`["8.8.8.8","1.1.1.1"]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := []map[string]interface{}{}
+	err = json.NewDecoder(res.Body).Decode(&batch)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 2 || batch[0]["country_code"] != "US" || batch[1]["status"] != float64(http.StatusNotFound) {
+		t.Errorf("Invalid batch response %v", batch)
+	}
+
+	h := ip2locationhttp.NewHandler(db)
+	h.MaxBatch = 2
+	long := `"ffff:ffff:ffff:ffff:ffff:ffff:255.255.255.255"`
+	for body, status := range map[string]int{
+		"[" + long + ",\n  " + long + "]":                  http.StatusOK,
+		`["8.8.8.8","8.8.8.8","8.8.8.8"]`:                  http.StatusRequestEntityTooLarge,
+		`["8.8.8.8"` + strings.Repeat(" ", 1<<20) + `]`:    http.StatusRequestEntityTooLarge,
+		`["8.8.8.8","` + strings.Repeat("8", 1<<20) + `"]`: http.StatusRequestEntityTooLarge,
+		`{"8.8.8.8"}`: http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/lookup?fields=country_code", strings.NewReader(body)))
+		if rec.Code != status {
+			t.Errorf("POST %.40q: status %d, expected %d", body, rec.Code, status)
+		}
+	}
+}
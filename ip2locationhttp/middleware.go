@@ -0,0 +1,180 @@
+package ip2locationhttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+
+	ip2location "github.com/alxarch/ip2location-go"
+)
+
+// NoLookupError is returned by FromContext for contexts not created by Middleware.
+var NoLookupError = errors.New("no lookup in context")
+
+// Middleware looks up the client of every request and stores the result in the request context.
+//
+// The client is the remote address of the connection. When that address belongs to TrustedProxies,
+// the Forwarded, X-Forwarded-For and X-Real-IP headers are consulted in that order and
+// the client is the rightmost address that is not a trusted proxy.
+type Middleware struct {
+	DB             ip2location.IP2LocationDB
+	Mode           ip2location.QueryMode
+	TrustedProxies []netip.Prefix
+	// Eager looks up every request before calling the next handler.
+	// Otherwise the lookup runs on the first call to FromContext.
+	Eager bool
+}
+
+// NewMiddleware creates a lazy Middleware that looks up mode in db.
+func NewMiddleware(db ip2location.IP2LocationDB, mode ip2location.QueryMode, trusted ...netip.Prefix) *Middleware {
+	return &Middleware{DB: db, Mode: mode, TrustedProxies: trusted}
+}
+
+type contextKey struct{}
+
+type lookup struct {
+	// ctx is the context of the request, so that the shared result does not depend on
+	// the context of whichever caller runs the lookup first
+	ctx  context.Context
+	db   ip2location.IP2LocationDB
+	mode ip2location.QueryMode
+	addr netip.Addr
+	once sync.Once
+	x    ip2location.Record
+	err  error
+}
+
+func (l *lookup) do() (*ip2location.Record, error) {
+	l.once.Do(func() {
+		if !l.addr.IsValid() {
+			l.err = ip2location.InvalidAddressError
+			return
+		}
+		l.err = l.db.QueryContext(l.ctx, l.addr.String(), &l.x, l.mode)
+	})
+	if l.err != nil {
+		return nil, l.err
+	}
+	return &l.x, nil
+}
+
+// Handler wraps next.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := &lookup{ctx: r.Context(), db: m.DB, mode: m.Mode, addr: m.ClientAddr(r)}
+		ctx := context.WithValue(r.Context(), contextKey{}, l)
+		if m.Eager {
+			l.do()
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// FromContext returns the record of the client of the request.
+// The lookup runs with the context of the request, whatever ctx is derived from it.
+// The record is shared by all callers and must not be modified.
+func FromContext(ctx context.Context) (*ip2location.Record, error) {
+	l, ok := ctx.Value(contextKey{}).(*lookup)
+	if !ok {
+		return nil, NoLookupError
+	}
+	return l.do()
+}
+
+// ClientAddrFromContext returns the client address found by Middleware.
+func ClientAddrFromContext(ctx context.Context) (netip.Addr, bool) {
+	l, ok := ctx.Value(contextKey{}).(*lookup)
+	if !ok || !l.addr.IsValid() {
+		return netip.Addr{}, false
+	}
+	return l.addr, true
+}
+
+// ClientAddr returns the client address of r.
+// The zero Addr is returned if the remote address is invalid.
+func (m *Middleware) ClientAddr(r *http.Request) netip.Addr {
+	addr := parseNode(r.RemoteAddr)
+	if !addr.IsValid() || !m.trusted(addr) {
+		return addr
+	}
+	if v := r.Header.Values("Forwarded"); len(v) > 0 {
+		return m.walk(addr, forwardedFor(v))
+	}
+	if v := r.Header.Values("X-Forwarded-For"); len(v) > 0 {
+		return m.walk(addr, splitList(v))
+	}
+	if v := r.Header.Get("X-Real-IP"); v != "" {
+		if real := parseNode(v); real.IsValid() {
+			return real
+		}
+	}
+	return addr
+}
+
+// walk returns the rightmost address of hops that is not trusted.
+// Unparseable hops end the walk at the last trusted address.
+func (m *Middleware) walk(addr netip.Addr, hops []string) netip.Addr {
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := parseNode(hops[i])
+		if !hop.IsValid() {
+			return addr
+		}
+		addr = hop
+		if !m.trusted(addr) {
+			return addr
+		}
+	}
+	return addr
+}
+
+func (m *Middleware) trusted(addr netip.Addr) bool {
+	for _, p := range m.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(values []string) (list []string) {
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			list = append(list, strings.TrimSpace(s))
+		}
+	}
+	return
+}
+
+// forwardedFor returns the for= parameters of RFC 7239 Forwarded headers.
+func forwardedFor(values []string) (hops []string) {
+	for _, elem := range splitList(values) {
+		node := ""
+		for _, pair := range strings.Split(elem, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			if strings.EqualFold(k, "for") {
+				node = strings.Trim(v, `"`)
+			}
+		}
+		hops = append(hops, node)
+	}
+	return
+}
+
+// parseNode parses an address with an optional port, as found in RemoteAddr and forwarding headers.
+func parseNode(s string) netip.Addr {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap()
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
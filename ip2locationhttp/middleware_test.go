@@ -0,0 +1,90 @@
+package ip2locationhttp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	ip2location "github.com/alxarch/ip2location-go"
+	"github.com/alxarch/ip2location-go/ip2locationhttp"
+)
+
+func Test_ClientAddr(t *testing.T) {
+	m := ip2locationhttp.NewMiddleware(nil, ip2location.QueryAll,
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	)
+	for _, tc := range []struct {
+		remote string
+		header map[string]string
+		expect string
+	}{
+		{"8.8.8.8:1234", nil, "8.8.8.8"},
+		{"[2001:db8::1]:1234", nil, "2001:db8::1"},
+		{"[::ffff:8.8.8.8]:1234", nil, "8.8.8.8"},
+		{"invalid", nil, "invalid IP"},
+		// untrusted remote addresses ignore headers
+		{"8.8.8.8:1234", map[string]string{"X-Forwarded-For": "1.1.1.1"}, "8.8.8.8"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.1.1.1"}, "1.1.1.1"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "6.6.6.6, 1.1.1.1, 10.0.0.2"}, "1.1.1.1"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.1.1.1, garbage, 10.0.0.2"}, "10.0.0.2"},
+		{"10.0.0.1:1234", map[string]string{"X-Real-IP": "1.1.1.1"}, "1.1.1.1"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": `for=6.6.6.6, for="[2001:db8::1]:80";proto=https, for=fd00::1`}, "2001:db8::1"},
+		{"10.0.0.1:1234", map[string]string{"Forwarded": "for=unknown", "X-Forwarded-For": "1.1.1.1"}, "10.0.0.1"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remote
+		for k, v := range tc.header {
+			r.Header.Set(k, v)
+		}
+		if addr := m.ClientAddr(r); addr.String() != tc.expect {
+			t.Errorf("Client of %s %v: %s, expected %s", tc.remote, tc.header, addr, tc.expect)
+		}
+	}
+}
+
+func Test_Middleware(t *testing.T) {
+	db := stubDB{"8.8.8.8": {CountryCode: "US"}}
+	if _, err := ip2locationhttp.FromContext(context.Background()); err != ip2locationhttp.NoLookupError {
+		t.Errorf("FromContext without middleware %v", err)
+	}
+	for _, eager := range []bool{false, true} {
+		m := ip2locationhttp.NewMiddleware(db, ip2location.QueryCountryCode)
+		m.Eager = eager
+		var x *ip2location.Record
+		var err error
+		h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			x, err = ip2locationhttp.FromContext(r.Context())
+		}))
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "8.8.8.8:1234"
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if err != nil || x.CountryCode != "US" {
+			t.Errorf("Eager %t: %v %v", eager, x, err)
+		}
+		r.RemoteAddr = "1.1.1.1:1234"
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if err != ip2location.NoMatchError || x != nil {
+			t.Errorf("Eager %t: %v %v", eager, x, err)
+		}
+	}
+
+	// the first caller's canceled context does not fail the lookup for later callers
+	m := ip2locationhttp.NewMiddleware(db, ip2location.QueryCountryCode)
+	var errs [2]error
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		cancel()
+		_, errs[0] = ip2locationhttp.FromContext(ctx)
+		_, errs[1] = ip2locationhttp.FromContext(r.Context())
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "8.8.8.8:1234"
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if errs[0] != nil || errs[1] != nil {
+		t.Errorf("Lookup with canceled caller context %v", errs)
+	}
+}
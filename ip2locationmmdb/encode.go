@@ -0,0 +1,144 @@
+package ip2locationmmdb
+
+import (
+	"encoding/binary"
+	"math"
+
+	ip2location "github.com/alxarch/ip2location-go"
+)
+
+// Data section types.
+const (
+	typePointer = 1
+	typeString  = 2
+	typeDouble  = 3
+	typeUint16  = 5
+	typeUint32  = 6
+	typeMap     = 7
+	typeUint64  = 9
+	typeArray   = 11
+	typeFloat   = 15
+)
+
+// encoder writes the data section, storing every record and longer string once.
+type encoder struct {
+	buf     []byte
+	strings map[string]uint32
+	records map[ip2location.Record]uint32
+}
+
+func newEncoder() *encoder {
+	return &encoder{
+		strings: map[string]uint32{},
+		records: map[ip2location.Record]uint32{},
+	}
+}
+
+// ctrl writes the control byte of a value of type t and the given size.
+func (e *encoder) ctrl(t byte, size int) {
+	var c byte
+	if t <= 7 {
+		c = t << 5
+	}
+	var n []byte
+	switch {
+	case size < 29:
+		c |= byte(size)
+	case size < 285:
+		c |= 29
+		n = []byte{byte(size - 29)}
+	case size < 65821:
+		c |= 30
+		size -= 285
+		n = []byte{byte(size >> 8), byte(size)}
+	default:
+		c |= 31
+		size -= 65821
+		n = []byte{byte(size >> 16), byte(size >> 8), byte(size)}
+	}
+	e.buf = append(e.buf, c)
+	if t > 7 {
+		e.buf = append(e.buf, t-7)
+	}
+	e.buf = append(e.buf, n...)
+}
+
+func (e *encoder) pointer(p uint32) {
+	switch {
+	case p < 2048:
+		e.buf = append(e.buf, typePointer<<5|byte(p>>8), byte(p))
+	case p < 526336:
+		p -= 2048
+		e.buf = append(e.buf, typePointer<<5|1<<3|byte(p>>16), byte(p>>8), byte(p))
+	case p < 134744064:
+		p -= 526336
+		e.buf = append(e.buf, typePointer<<5|2<<3|byte(p>>24), byte(p>>16), byte(p>>8), byte(p))
+	default:
+		e.buf = append(e.buf, typePointer<<5|3<<3)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, p)
+	}
+}
+
+// string writes s or a pointer to where it was written before.
+func (e *encoder) string(s string) {
+	if p, ok := e.strings[s]; ok {
+		e.pointer(p)
+		return
+	}
+	if len(s) > 2 {
+		e.strings[s] = uint32(len(e.buf))
+	}
+	e.ctrl(typeString, len(s))
+	e.buf = append(e.buf, s...)
+}
+
+// uint writes v with leading zero bytes dropped.
+func (e *encoder) uint(t byte, v uint64) {
+	n := 0
+	for x := v; x != 0; x >>= 8 {
+		n++
+	}
+	e.ctrl(t, n)
+	for i := n - 1; i >= 0; i-- {
+		e.buf = append(e.buf, byte(v>>(8*i)))
+	}
+}
+
+func (e *encoder) float(f float32) {
+	e.ctrl(typeFloat, 4)
+	e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(f))
+}
+
+func (e *encoder) double(f float64) {
+	e.ctrl(typeDouble, 8)
+	e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(f))
+}
+
+// record writes the populated fields of x as a map, once for every distinct record, and returns its offset.
+func (e *encoder) record(x *ip2location.Record) (uint32, error) {
+	if off, ok := e.records[*x]; ok {
+		return off, nil
+	}
+	if uint64(len(e.buf)) >= uint64(dataBit) {
+		return 0, DatabaseSizeError
+	}
+	off := uint32(len(e.buf))
+	fields := x.Mode.Fields()
+	e.ctrl(typeMap, len(fields))
+	for _, m := range fields {
+		e.string(m.Name())
+		switch m {
+		case ip2location.QueryLatitude:
+			e.float(x.Latitude)
+		case ip2location.QueryLongitude:
+			e.float(x.Longitude)
+		case ip2location.QueryElevation:
+			e.double(x.Elevation)
+		default:
+			s, _ := x.Value(m).(string)
+			e.string(s)
+		}
+	}
+	e.records[*x] = off
+	return off, nil
+}
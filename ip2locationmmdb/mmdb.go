@@ -0,0 +1,265 @@
+// Package ip2locationmmdb converts IP2Location databases to the MaxMind DB format.
+//
+// Every block of the IP2Location tables becomes a map in the data section keyed by the
+// IP2Location column names of the converted fields, such as country_code and city_name.
+// Latitude and longitude are stored as floats and elevation as a double.
+package ip2locationmmdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+
+	ip2location "github.com/alxarch/ip2location-go"
+)
+
+// DatabaseSizeError is returned for databases too large for the MaxMind DB format.
+var DatabaseSizeError = errors.New("database too large for MaxMind DB format")
+
+// Options control Convert.
+type Options struct {
+	// Mode selects the converted fields; zero converts every field of the database.
+	Mode ip2location.QueryMode
+	// DatabaseType is stored in the metadata and defaults to IP2Location-DBn.
+	DatabaseType string
+	// Description is the English description in the metadata and defaults to the type and date of the database.
+	Description string
+	// RecordSize is the size in bits of the search tree records, 24, 28 or 32;
+	// zero picks the smallest that fits.
+	RecordSize int
+}
+
+// metadataStart marks the start of the metadata section.
+const metadataStart = "\xab\xcd\xefMaxMind.com"
+
+// Convert writes db in MaxMind DB format.
+// Databases with an IPv6 table produce an IPv6 tree with IPv4 addresses under ::/96 and
+// aliased at ::ffff:0:0/96; others produce an IPv4 tree.
+func Convert(w io.Writer, db *ip2location.DB, opts Options) error {
+	meta := db.Meta()
+	mode := opts.Mode & db.SupportedModes()
+	if opts.Mode == 0 {
+		mode = db.SupportedModes()
+	}
+	if mode == 0 {
+		return ip2location.NotSupportedError
+	}
+	switch opts.RecordSize {
+	case 0, 24, 28, 32:
+	default:
+		return fmt.Errorf("invalid record size %d", opts.RecordSize)
+	}
+	ipv6 := meta.Has(ip2location.IPv6)
+	t := &tree{nodes: make([][2]record, 1)}
+	e := newEncoder()
+	if ipv6 {
+		if err := t.insert(db, ip2location.IPv6, mode, e, 0); err != nil {
+			return err
+		}
+		// the IPv4 table replaces the IPv6 rows of ::/96 and is aliased at ::ffff:0:0/96
+		t.set(ip2location.Uint128{}, 96, empty)
+		if err := t.insert(db, ip2location.IPv4, mode, e, 96); err != nil {
+			return err
+		}
+		t.set(ip2location.Uint128{Lo: 0xffff << 32}, 96, t.get(ip2location.Uint128{}, 96))
+	} else if err := t.insert(db, ip2location.IPv4, mode, e, 0); err != nil {
+		return err
+	}
+
+	order, num := t.number()
+	nodeCount := uint64(len(order))
+	size := nodeCount + 16 + uint64(len(e.buf))
+	recordSize := opts.RecordSize
+	if recordSize == 0 {
+		switch {
+		case size < 1<<24:
+			recordSize = 24
+		case size < 1<<28:
+			recordSize = 28
+		default:
+			recordSize = 32
+		}
+	}
+	if size >= 1<<recordSize {
+		return DatabaseSizeError
+	}
+	value := func(r record) uint32 {
+		switch {
+		case r == empty:
+			return uint32(nodeCount)
+		case r&dataBit != 0:
+			return uint32(nodeCount + 16 + uint64(r&^dataBit))
+		}
+		return num[r]
+	}
+
+	bw := bufio.NewWriter(w)
+	node := make([]byte, recordSize/4)
+	for _, n := range order {
+		left, right := value(t.nodes[n][0]), value(t.nodes[n][1])
+		switch recordSize {
+		case 24:
+			putUint24(node, left)
+			putUint24(node[3:], right)
+		case 28:
+			putUint24(node, left)
+			node[3] = byte(left>>24)<<4 | byte(right>>24)
+			putUint24(node[4:], right)
+		case 32:
+			binary.BigEndian.PutUint32(node, left)
+			binary.BigEndian.PutUint32(node[4:], right)
+		}
+		bw.Write(node)
+	}
+	bw.Write(make([]byte, 16))
+	bw.Write(e.buf)
+	bw.WriteString(metadataStart)
+
+	typ := opts.DatabaseType
+	if typ == "" {
+		typ = "IP2Location-" + meta.Type().String()
+	}
+	desc := opts.Description
+	if desc == "" {
+		desc = fmt.Sprintf("IP2Location %s %s", meta.Type(), meta.Date().Format("2006-01-02"))
+	}
+	ipVersion := 4
+	if ipv6 {
+		ipVersion = 6
+	}
+	m := newEncoder()
+	m.ctrl(typeMap, 9)
+	m.string("binary_format_major_version")
+	m.uint(typeUint16, 2)
+	m.string("binary_format_minor_version")
+	m.uint(typeUint16, 0)
+	m.string("build_epoch")
+	m.uint(typeUint64, uint64(meta.Date().Unix()))
+	m.string("database_type")
+	m.string(typ)
+	m.string("description")
+	m.ctrl(typeMap, 1)
+	m.string("en")
+	m.string(desc)
+	m.string("ip_version")
+	m.uint(typeUint16, uint64(ipVersion))
+	m.string("languages")
+	m.ctrl(typeArray, 1)
+	m.string("en")
+	m.string("node_count")
+	m.uint(typeUint32, nodeCount)
+	m.string("record_size")
+	m.uint(typeUint16, uint64(recordSize))
+	bw.Write(m.buf)
+	return bw.Flush()
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v>>16), byte(v>>8), byte(v)
+}
+
+// record is a tree record: empty, a node index or a data section offset with dataBit set.
+type record uint32
+
+const (
+	empty   record = 0 // the root is never a child
+	dataBit record = 1 << 31
+)
+
+// tree is a binary trie of address bits, most significant first.
+type tree struct {
+	nodes [][2]record
+}
+
+// bit returns bit i of ip counting from the most significant.
+func bit(ip ip2location.Uint128, i int) int {
+	if i < 64 {
+		return int(ip.Hi>>(63-i)) & 1
+	}
+	return int(ip.Lo>>(127-i)) & 1
+}
+
+// set stores v for the first bits of ip, replacing what was stored below them.
+func (t *tree) set(ip ip2location.Uint128, bits int, v record) {
+	if bits == 0 {
+		t.nodes[0] = [2]record{v, v}
+		return
+	}
+	n := record(0)
+	for i := 0; i < bits-1; i++ {
+		b := bit(ip, i)
+		r := t.nodes[n][b]
+		if r == empty || r&dataBit != 0 {
+			// split a covering record
+			t.nodes = append(t.nodes, [2]record{r, r})
+			r = record(len(t.nodes) - 1)
+			t.nodes[n][b] = r
+		}
+		n = r
+	}
+	t.nodes[n][bit(ip, bits-1)] = v
+}
+
+// get returns the record for the first bits of ip.
+func (t *tree) get(ip ip2location.Uint128, bits int) record {
+	n := record(0)
+	for i := 0; i < bits; i++ {
+		r := t.nodes[n][bit(ip, i)]
+		if r == empty || r&dataBit != 0 || i == bits-1 {
+			return r
+		}
+		n = r
+	}
+	return n
+}
+
+// insert adds the blocks of table typ below the first depth bits of the tree.
+func (t *tree) insert(db *ip2location.DB, typ ip2location.IPType, mode ip2location.QueryMode, e *encoder, depth int) error {
+	c := db.Ranges(typ, mode)
+	for r, x := range c.All() {
+		off, err := e.record(x)
+		if err != nil {
+			return err
+		}
+		for _, p := range r.Prefixes() {
+			t.set(number(p.Addr(), depth), depth+p.Bits(), dataBit|record(off))
+		}
+	}
+	return c.Err()
+}
+
+// number returns the tree path of addr, placing IPv4 addresses below the first depth bits.
+func number(addr netip.Addr, depth int) ip2location.Uint128 {
+	if addr.Is4() {
+		b := addr.As4()
+		n := uint64(binary.BigEndian.Uint32(b[:]))
+		if depth == 0 {
+			return ip2location.Uint128{Hi: n << 32}
+		}
+		return ip2location.Uint128{Lo: n}
+	}
+	b := addr.As16()
+	return ip2location.Uint128{Hi: binary.BigEndian.Uint64(b[:8]), Lo: binary.BigEndian.Uint64(b[8:])}
+}
+
+// number returns the reachable nodes in breadth first order and their numbers by index.
+func (t *tree) number() ([]record, []uint32) {
+	num := make([]uint32, len(t.nodes))
+	seen := make([]bool, len(t.nodes))
+	order := []record{0}
+	seen[0] = true
+	for i := 0; i < len(order); i++ {
+		n := order[i]
+		num[n] = uint32(i)
+		for _, r := range t.nodes[n] {
+			if r != empty && r&dataBit == 0 && !seen[r] {
+				seen[r] = true
+				order = append(order, r)
+			}
+		}
+	}
+	return order, num
+}
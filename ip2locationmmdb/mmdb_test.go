@@ -0,0 +1,377 @@
+package ip2locationmmdb_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/netip"
+	"strings"
+	"testing"
+
+	ip2loc "github.com/alxarch/ip2location-go"
+	"github.com/alxarch/ip2location-go/ip2locationmmdb"
+	"github.com/alxarch/ip2location-go/ip2locationtest"
+)
+
+// reader decodes MaxMind DB files as described in the format specification.
+type reader struct {
+	t          *testing.T
+	data       []byte
+	tree, meta []byte
+	metadata   map[string]interface{}
+	nodeCount  uint32
+	recordSize uint32
+	ipVersion  uint16
+}
+
+func newReader(t *testing.T, data []byte) *reader {
+	i := bytes.LastIndex(data, []byte("\xab\xcd\xefMaxMind.com"))
+	if i < 0 {
+		t.Fatal("Missing metadata")
+	}
+	r := &reader{t: t, meta: data[i+14:]}
+	meta, _ := r.decode(r.meta, 0)
+	m := meta.(map[string]interface{})
+	r.metadata = m
+	r.nodeCount = uint32(m["node_count"].(uint64))
+	r.recordSize = uint32(m["record_size"].(uint64))
+	r.ipVersion = uint16(m["ip_version"].(uint64))
+	if m["binary_format_major_version"].(uint64) != 2 {
+		t.Fatalf("Invalid metadata %v", m)
+	}
+	size := r.nodeCount * r.recordSize / 4
+	r.tree = data[:size]
+	if !bytes.Equal(data[size:size+16], make([]byte, 16)) {
+		t.Fatal("Missing data section separator")
+	}
+	r.data = data[size+16 : i]
+	return r
+}
+
+func (r *reader) record(node uint32, b int) uint32 {
+	n := r.tree[node*r.recordSize/4:]
+	switch r.recordSize {
+	case 24:
+		n = n[b*3:]
+		return uint32(n[0])<<16 | uint32(n[1])<<8 | uint32(n[2])
+	case 28:
+		if b == 0 {
+			return uint32(n[3]>>4)<<24 | uint32(n[0])<<16 | uint32(n[1])<<8 | uint32(n[2])
+		}
+		return uint32(n[3]&0xf)<<24 | uint32(n[4])<<16 | uint32(n[5])<<8 | uint32(n[6])
+	}
+	return binary.BigEndian.Uint32(n[b*4:])
+}
+
+// lookup returns the data of addr or nil.
+func (r *reader) lookup(addr netip.Addr) interface{} {
+	ip := addr.AsSlice()
+	if r.ipVersion == 6 && addr.Is4() {
+		ip = append(make([]byte, 12), ip...)
+	}
+	node := uint32(0)
+	for i := 0; i < len(ip)*8 && node < r.nodeCount; i++ {
+		node = r.record(node, int(ip[i/8]>>(7-i%8))&1)
+	}
+	if node == r.nodeCount {
+		return nil
+	}
+	v, _ := r.decode(r.data, int(node-r.nodeCount-16))
+	return v
+}
+
+func (r *reader) decode(data []byte, off int) (interface{}, int) {
+	c := data[off]
+	off++
+	t := int(c >> 5)
+	if t == 1 {
+		ss, v := (c>>3)&3, int(c&7)
+		var p int
+		switch ss {
+		case 0:
+			p = v<<8 | int(data[off])
+		case 1:
+			p = (v<<16 | int(data[off])<<8 | int(data[off+1])) + 2048
+		case 2:
+			p = (v<<24 | int(data[off])<<16 | int(data[off+1])<<8 | int(data[off+2])) + 526336
+		case 3:
+			p = int(binary.BigEndian.Uint32(data[off:]))
+		}
+		val, _ := r.decode(data, p)
+		return val, off + int(ss) + 1
+	}
+	if t == 0 {
+		t = int(data[off]) + 7
+		off++
+	}
+	size := int(c & 31)
+	switch size {
+	case 29:
+		size = 29 + int(data[off])
+		off++
+	case 30:
+		size = 285 + (int(data[off])<<8 | int(data[off+1]))
+		off += 2
+	case 31:
+		size = 65821 + (int(data[off])<<16 | int(data[off+1])<<8 | int(data[off+2]))
+		off += 3
+	}
+	switch t {
+	case 2:
+		return string(data[off : off+size]), off + size
+	case 3:
+		return math.Float64frombits(binary.BigEndian.Uint64(data[off:])), off + 8
+	case 15:
+		return math.Float32frombits(binary.BigEndian.Uint32(data[off:])), off + 4
+	case 5, 6, 9:
+		var v uint64
+		for _, b := range data[off : off+size] {
+			v = v<<8 | uint64(b)
+		}
+		return v, off + size
+	case 7:
+		m := map[string]interface{}{}
+		for i := 0; i < size; i++ {
+			var k, v interface{}
+			k, off = r.decode(data, off)
+			v, off = r.decode(data, off)
+			m[k.(string)] = v
+		}
+		return m, off
+	case 11:
+		a := make([]interface{}, size)
+		for i := range a {
+			a[i], off = r.decode(data, off)
+		}
+		return a, off
+	}
+	r.t.Fatalf("Unexpected type %d", t)
+	return nil, off
+}
+
+func Test_Convert(t *testing.T) {
+	gr := ip2locationtest.Record(ip2loc.DB5.Modes(), "GR")
+	us := ip2locationtest.Record(ip2loc.DB5.Modes(), "US")
+	spec := ip2locationtest.Spec{
+		Type: ip2loc.DB5,
+		Blocks: []ip2locationtest.Block{
+			{Range: "1.0.0.0-1.0.2.255", Record: gr},
+			{Range: "128.0.0.0-255.255.255.255", Record: us},
+			{Range: "::ffff:0:0/96", Record: us},
+			{Range: "2001:db8::/32", Record: gr},
+		},
+	}
+	db := ip2locationtest.New(t, spec)
+	for _, opts := range []ip2locationmmdb.Options{
+		{},
+		{Mode: ip2loc.QueryCountryCode | ip2loc.QueryLatitude},
+		{RecordSize: 28},
+		{RecordSize: 32},
+	} {
+		buf := bytes.Buffer{}
+		if err := ip2locationmmdb.Convert(&buf, db, opts); err != nil {
+			t.Fatal(err)
+		}
+		r := newReader(t, buf.Bytes())
+		if r.ipVersion != 6 {
+			t.Errorf("Invalid ip version %d", r.ipVersion)
+		}
+		if size := uint32(opts.RecordSize); size != 0 && r.recordSize != size {
+			t.Errorf("Invalid record size %d", r.recordSize)
+		}
+		mode := opts.Mode
+		if mode == 0 {
+			mode = ip2loc.DB5.Modes()
+		}
+		for ip, want := range map[string]*ip2loc.Record{
+			"1.0.0.0":         &gr,
+			"1.0.2.255":       &gr,
+			"::ffff:1.0.1.1":  &gr,
+			"::1.0.1.1":       &gr,
+			"1.0.3.0":         nil,
+			"0.0.0.0":         nil,
+			"128.0.0.0":       &us,
+			"255.255.255.255": &us,
+			"2001:db8::1":     &gr,
+			"2001:db9::":      nil,
+		} {
+			v := r.lookup(netip.MustParseAddr(ip))
+			if want == nil {
+				if v != nil {
+					t.Errorf("%s: unexpected data %v", ip, v)
+				}
+				continue
+			}
+			m, ok := v.(map[string]interface{})
+			if !ok || len(m) != len(mode.Fields()) {
+				t.Errorf("%s: invalid data %v", ip, v)
+				continue
+			}
+			for _, f := range mode.Fields() {
+				if m[f.Name()] != want.Value(f) {
+					t.Errorf("%s: invalid %s %v", ip, f.Name(), m[f.Name()])
+				}
+			}
+		}
+	}
+
+	spec.Blocks = spec.Blocks[:2]
+	buf := bytes.Buffer{}
+	if err := ip2locationmmdb.Convert(&buf, ip2locationtest.New(t, spec), ip2locationmmdb.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	r := newReader(t, buf.Bytes())
+	if r.ipVersion != 4 {
+		t.Errorf("Invalid ip version %d", r.ipVersion)
+	}
+	if m, _ := r.lookup(netip.MustParseAddr("1.0.1.0")).(map[string]interface{}); m["country_code"] != "GR" {
+		t.Errorf("Invalid data %v", m)
+	}
+	if v := r.lookup(netip.MustParseAddr("1.0.3.0")); v != nil {
+		t.Errorf("Unexpected data %v", v)
+	}
+	if err := ip2locationmmdb.Convert(&bytes.Buffer{}, db, ip2locationmmdb.Options{RecordSize: 20}); err == nil {
+		t.Error("Converted with invalid record size")
+	}
+}
+
+// str encodes a string shorter than 29 bytes.
+func str(s string) []byte {
+	return append([]byte{2<<5 | byte(len(s))}, s...)
+}
+
+// Test_ConvertFixture compares a conversion with a file built by hand from the format specification.
+func Test_ConvertFixture(t *testing.T) {
+	db := ip2locationtest.New(t, ip2locationtest.Spec{
+		Type:   ip2loc.DB1,
+		Blocks: []ip2locationtest.Block{{Range: "0.0.0.0/1", Record: ip2locationtest.Record(ip2loc.DB1.Modes(), "GR")}},
+	})
+	// the root node points left to the data at node count + 16 and right to the node count, for no data
+	for size, root := range map[int][]byte{
+		24: {0x00, 0x00, 0x11, 0x00, 0x00, 0x01},
+		28: {0x00, 0x00, 0x11, 0x00, 0x00, 0x00, 0x01},
+		32: {0x00, 0x00, 0x00, 0x11, 0x00, 0x00, 0x00, 0x01},
+	} {
+		var want []byte
+		want = append(want, root...)
+		want = append(want, make([]byte, 16)...)
+		want = append(want, 0xe1)
+		want = append(want, str("country_code")...)
+		want = append(want, str("GR")...)
+		want = append(want, "\xab\xcd\xefMaxMind.com"...)
+		want = append(want, 0xe9)
+		want = append(want, str("binary_format_major_version")...)
+		want = append(want, 0xa1, 0x02)
+		want = append(want, str("binary_format_minor_version")...)
+		want = append(want, 0xa0)
+		want = append(want, str("build_epoch")...)
+		want = append(want, 0x04, 0x02, 0x5e, 0x0b, 0xe1, 0x00)
+		want = append(want, str("database_type")...)
+		want = append(want, str("Fixture")...)
+		want = append(want, str("description")...)
+		want = append(want, 0xe1)
+		want = append(want, str("en")...)
+		want = append(want, str("Hand built")...)
+		want = append(want, str("ip_version")...)
+		want = append(want, 0xa1, 0x04)
+		want = append(want, str("languages")...)
+		want = append(want, 0x01, 0x04)
+		want = append(want, str("en")...)
+		want = append(want, str("node_count")...)
+		want = append(want, 0xc1, 0x01)
+		want = append(want, str("record_size")...)
+		want = append(want, 0xa1, byte(size))
+
+		buf := bytes.Buffer{}
+		opts := ip2locationmmdb.Options{Mode: ip2loc.QueryCountryCode, DatabaseType: "Fixture", Description: "Hand built", RecordSize: size}
+		if err := ip2locationmmdb.Convert(&buf, db, opts); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%d bit records:\n% x\nexpected\n% x", size, buf.Bytes(), want)
+		}
+	}
+}
+
+// Test_ConvertLarge converts a data section past 24 bit records,
+// where the high bits of records share the middle byte of their node.
+// The last /16 is left empty so the records of its parent node differ in their high bits.
+func Test_ConvertLarge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("large database")
+	}
+	spec := ip2locationtest.Spec{Type: ip2loc.DB3}
+	city := func(i int) string {
+		return fmt.Sprintf("%s%05d", strings.Repeat("c", 250), i)
+	}
+	for i := 0; i < 1<<16-1; i++ {
+		x := ip2locationtest.Record(ip2loc.DB3.Modes(), "GR")
+		x.City = city(i)
+		spec.Blocks = append(spec.Blocks, ip2locationtest.Block{Range: fmt.Sprintf("%d.%d.0.0/16", i>>8, i&0xff), Record: x})
+	}
+	buf := bytes.Buffer{}
+	if err := ip2locationmmdb.Convert(&buf, ip2locationtest.New(t, spec), ip2locationmmdb.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	r := newReader(t, buf.Bytes())
+	if r.recordSize != 28 || uint64(len(r.data)) < 1<<24 {
+		t.Fatalf("Invalid record size %d for %d bytes of data", r.recordSize, len(r.data))
+	}
+	for _, i := range []int{0, 1, 1 << 15, 1<<16 - 3, 1<<16 - 2} {
+		ip := netip.AddrFrom4([4]byte{byte(i >> 8), byte(i), 1, 1})
+		if m, _ := r.lookup(ip).(map[string]interface{}); m["city_name"] != city(i) {
+			t.Errorf("%s: invalid data %v", ip, m)
+		}
+	}
+	if v := r.lookup(netip.MustParseAddr("255.255.1.1")); v != nil {
+		t.Errorf("Unexpected data %v", v)
+	}
+}
+
+func Test_ConvertLongStrings(t *testing.T) {
+	db := ip2locationtest.New(t, ip2locationtest.Spec{
+		Type:   ip2loc.DB1,
+		Blocks: []ip2locationtest.Block{{Range: "1.0.0.0/24", Record: ip2locationtest.Record(ip2loc.DB1.Modes(), "GR")}},
+	})
+	// lengths around the size extensions of control bytes
+	for _, n := range []int{28, 29, 284, 285, 65820, 65821, 70000} {
+		opts := ip2locationmmdb.Options{DatabaseType: strings.Repeat("t", n), Description: strings.Repeat("d", n)}
+		buf := bytes.Buffer{}
+		if err := ip2locationmmdb.Convert(&buf, db, opts); err != nil {
+			t.Fatal(err)
+		}
+		m := newReader(t, buf.Bytes()).metadata
+		desc, _ := m["description"].(map[string]interface{})
+		if m["database_type"] != opts.DatabaseType || desc["en"] != opts.Description {
+			t.Errorf("%d: invalid metadata lengths %d %d", n, len(m["database_type"].(string)), len(desc["en"].(string)))
+		}
+	}
+}
+
+func Test_DecodeMaps(t *testing.T) {
+	r := &reader{t: t}
+	for _, tc := range []struct {
+		size int
+		ctrl []byte
+	}{
+		{28, []byte{0xe0 | 28}},
+		{29, []byte{0xe0 | 29, 0}},
+		{284, []byte{0xe0 | 29, 255}},
+		{285, []byte{0xe0 | 30, 0, 0}},
+		{300, []byte{0xe0 | 30, 0, 15}},
+		{65821, []byte{0xe0 | 31, 0, 0, 0}},
+		{65822, []byte{0xe0 | 31, 0, 0, 1}},
+	} {
+		data := append([]byte{}, tc.ctrl...)
+		for i := 0; i < tc.size; i++ {
+			data = append(data, str(fmt.Sprintf("k%d", i))...)
+			data = append(data, 0xa1, byte(i))
+		}
+		v, off := r.decode(data, 0)
+		m, _ := v.(map[string]interface{})
+		if len(m) != tc.size || off != len(data) || m[fmt.Sprintf("k%d", tc.size-1)] != uint64(byte(tc.size-1)) {
+			t.Errorf("%d: decoded %d entries", tc.size, len(m))
+		}
+	}
+}
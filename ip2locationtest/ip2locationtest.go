@@ -0,0 +1,199 @@
+// Package ip2locationtest builds small IP2Location databases in memory for tests.
+package ip2locationtest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	ip2location "github.com/alxarch/ip2location-go"
+	"github.com/alxarch/ip2location-go/ip2locationwriter"
+)
+
+// DefaultDate is the release date of databases without a Date.
+var DefaultDate = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// block is a range of addresses and the record stored for it.
+// Range is an address, a CIDR prefix or two addresses separated by a dash.
+// IPv4 addresses go to the IPv4 table and IPv6 addresses, IPv4-mapped ones included, to the IPv6 table.
+type block[R any] struct {
+	Range  string
+	Record R
+}
+
+// Block is a range of addresses and the record stored for it in an IP2Location database.
+type Block = block[ip2location.Record]
+
+// Spec declares a database.
+// Addresses not covered by any block do not match.
+type Spec struct {
+	Type    ip2location.DBType
+	Date    time.Time
+	NoIndex bool
+	Blocks  []Block
+}
+
+// ParseRange parses the Range of a Block.
+func ParseRange(s string) (ip2location.Range, error) {
+	var from, to netip.Addr
+	var err error
+	if a, b, ok := strings.Cut(s, "-"); ok {
+		if from, err = netip.ParseAddr(strings.TrimSpace(a)); err == nil {
+			to, err = netip.ParseAddr(strings.TrimSpace(b))
+		}
+	} else if p, perr := netip.ParsePrefix(s); perr == nil {
+		from, to = p.Masked().Addr(), last(p.Masked())
+	} else {
+		from, err = netip.ParseAddr(s)
+		to = from
+	}
+	if err != nil {
+		return ip2location.Range{}, err
+	}
+	if from.Is4() != to.Is4() || to.Less(from) {
+		return ip2location.Range{}, fmt.Errorf("invalid range %q", s)
+	}
+	r := ip2location.Range{Type: ip2location.IPv4}
+	if from.Is6() {
+		r.Type = ip2location.IPv6
+	}
+	r.From, r.To = number(from), number(to)
+	if to.Next().IsValid() {
+		// the block of the maximum address ends at it
+		r.To = r.To.Add1()
+	}
+	return r, nil
+}
+
+// number returns the IP number of addr without unmapping IPv4-mapped addresses.
+func number(addr netip.Addr) ip2location.Uint128 {
+	n, _ := ip2location.AddrNumber(addr)
+	if addr.Is4In6() {
+		n.Lo |= 0xffff << 32
+	}
+	return n
+}
+
+// last returns the last address of p.
+func last(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	bits := p.Bits()
+	for i := range b {
+		for j := 7; j >= 0; j-- {
+			if bits <= 0 {
+				b[i] |= 1 << j
+			}
+			bits--
+		}
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// writer is implemented by the writers of ip2locationwriter for records of type R.
+type writer[R any] interface {
+	AddRange(r ip2location.Range, x *R) error
+	WriteTo(out io.Writer) (int64, error)
+}
+
+// dateOr returns date or DefaultDate if it is zero.
+func dateOr(date time.Time) time.Time {
+	if date.IsZero() {
+		return DefaultDate
+	}
+	return date
+}
+
+// write adds blocks to w and returns the written file.
+func write[R any](w writer[R], blocks []block[R]) ([]byte, error) {
+	for i := range blocks {
+		b := &blocks[i]
+		r, err := ParseRange(b.Range)
+		if err != nil {
+			return nil, err
+		}
+		if err := w.AddRange(r, &b.Record); err != nil {
+			return nil, fmt.Errorf("%s: %w", b.Range, err)
+		}
+	}
+	buf := bytes.Buffer{}
+	if _, err := w.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// open opens the file returned by data with newDB.
+func open[D any](data func() ([]byte, error), newDB func(r io.ReaderAt) (D, error)) (D, error) {
+	b, err := data()
+	if err != nil {
+		var zero D
+		return zero, err
+	}
+	return newDB(bytes.NewReader(b))
+}
+
+// must returns the database opened by db or fails the test.
+func must[D any](tb testing.TB, db func() (D, error)) D {
+	tb.Helper()
+	d, err := db()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return d
+}
+
+// Bytes returns the BIN file of s.
+func (s *Spec) Bytes() ([]byte, error) {
+	w, err := ip2locationwriter.New(s.Type, dateOr(s.Date))
+	if err != nil {
+		return nil, err
+	}
+	w.Index = !s.NoIndex
+	return write(w, s.Blocks)
+}
+
+// DB opens the database of s.
+func (s *Spec) DB() (*ip2location.DB, error) {
+	return open(s.Bytes, ip2location.NewDB)
+}
+
+// New opens the database of s or fails the test.
+func New(tb testing.TB, s Spec) *ip2location.DB {
+	tb.Helper()
+	return must(tb, s.DB)
+}
+
+// Record returns a record with every field of mode set.
+// The country code is code and other text fields are named after the field and code,
+// so that records of different codes differ in every field.
+func Record(mode ip2location.QueryMode, code string) ip2location.Record {
+	x := ip2location.Record{}
+	var n float32
+	for _, c := range code {
+		n = n*32 + float32(c%32)
+	}
+	for _, m := range mode.Fields() {
+		var err error
+		switch m {
+		case ip2location.QueryCountryCode:
+			err = x.Set(m, code)
+		case ip2location.QueryLatitude:
+			x.Latitude, x.Mode = n/16, x.Mode|m
+		case ip2location.QueryLongitude:
+			x.Longitude, x.Mode = -n/8, x.Mode|m
+		case ip2location.QueryElevation:
+			x.Elevation, x.Mode = float64(n), x.Mode|m
+		default:
+			err = x.Set(m, m.Name()+" "+code)
+		}
+		if err != nil {
+			panic(err)
+		}
+	}
+	return x
+}
@@ -0,0 +1,55 @@
+package ip2locationtest
+
+import (
+	"testing"
+	"time"
+
+	ip2location "github.com/alxarch/ip2location-go"
+	"github.com/alxarch/ip2location-go/ip2locationwriter"
+)
+
+// ProxyBlock is a range of addresses and the proxy record stored for it, as in Block.
+type ProxyBlock = block[ip2location.ProxyRecord]
+
+// ProxySpec declares an IP2Proxy database.
+// Addresses not covered by any block do not match.
+type ProxySpec struct {
+	Type    ip2location.ProxyDBType
+	Date    time.Time
+	NoIndex bool
+	Blocks  []ProxyBlock
+}
+
+// Bytes returns the BIN file of s.
+func (s *ProxySpec) Bytes() ([]byte, error) {
+	w, err := ip2locationwriter.NewProxy(s.Type, dateOr(s.Date))
+	if err != nil {
+		return nil, err
+	}
+	w.Index = !s.NoIndex
+	return write(w, s.Blocks)
+}
+
+// DB opens the database of s.
+func (s *ProxySpec) DB() (*ip2location.ProxyDB, error) {
+	return open(s.Bytes, ip2location.NewProxyDB)
+}
+
+// NewProxy opens the database of s or fails the test.
+func NewProxy(tb testing.TB, s ProxySpec) *ip2location.ProxyDB {
+	tb.Helper()
+	return must(tb, s.DB)
+}
+
+// ProxyRecord returns a proxy record with every field of mode set, named as in Record.
+func ProxyRecord(mode ip2location.ProxyQueryMode, code string) ip2location.ProxyRecord {
+	x := ip2location.ProxyRecord{}
+	for _, m := range mode.Fields() {
+		if m == ip2location.ProxyQueryCountryCode {
+			x.Set(m, code)
+		} else {
+			x.Set(m, m.Name()+" "+code)
+		}
+	}
+	return x
+}
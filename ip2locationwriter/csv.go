@@ -0,0 +1,79 @@
+package ip2locationwriter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+
+	ip2location "github.com/alxarch/ip2location-go"
+)
+
+// fields returns the CSV columns following ip_from and ip_to, in the order of the IP2Location CSV files.
+func (w *Writer) fields() []ip2location.QueryMode {
+	var fields []ip2location.QueryMode
+	for _, m := range w.cols {
+		fields = append(fields, m.Fields()...)
+	}
+	return fields
+}
+
+// ReadCSV adds the ranges of an IP2Location CSV file for the Writer's database type.
+// Addresses are IP numbers of type t, as in the IP2Location files, or textual addresses.
+// A leading header row starting with ip_from is skipped.
+func (w *Writer) ReadCSV(r io.Reader, t ip2location.IPType) error {
+	fields := w.fields()
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(fields) + 2
+	cr.ReuseRecord = true
+	for line := 1; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if line == 1 && row[0] == "ip_from" {
+			continue
+		}
+		from, err := parseAddr(row[0], t)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		to, err := parseAddr(row[1], t)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		x := ip2location.Record{}
+		for i, m := range fields {
+			if err := x.Set(m, row[i+2]); err != nil {
+				return fmt.Errorf("line %d: %s: %w", line, m.Name(), err)
+			}
+		}
+		if err := w.Add(from, to, &x); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// parseAddr parses an IP number of type t or a textual address.
+func parseAddr(s string, t ip2location.IPType) (netip.Addr, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr, nil
+	}
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 || n.BitLen() > 128 || t == ip2location.IPv4 && n.BitLen() > 32 {
+		return netip.Addr{}, ip2location.InvalidAddressError
+	}
+	var b [16]byte
+	n.FillBytes(b[:])
+	switch t {
+	case ip2location.IPv4:
+		return netip.AddrFrom4([4]byte(b[12:])), nil
+	case ip2location.IPv6:
+		return netip.AddrFrom16(b), nil
+	}
+	return netip.Addr{}, ip2location.UnsupportedAddressTypeError
+}
@@ -0,0 +1,62 @@
+package ip2locationwriter
+
+import (
+	"io"
+	"net/netip"
+	"time"
+
+	ip2location "github.com/alxarch/ip2location-go"
+)
+
+// ProxyWriter collects address ranges and writes them as an IP2Proxy BIN database.
+// Addresses not covered by any range do not match when queried.
+type ProxyWriter struct {
+	// Index writes the first-16-bits index tables that narrow searches.
+	Index bool
+
+	t      ip2location.ProxyDBType
+	date   time.Time
+	cols   []ip2location.ProxyQueryMode
+	blocks tables[ip2location.ProxyRecord]
+}
+
+// NewProxy creates a ProxyWriter for proxy databases of type t released on date.
+func NewProxy(t ip2location.ProxyDBType, date time.Time) (*ProxyWriter, error) {
+	cols := t.Columns()
+	if len(cols) == 0 {
+		return nil, ip2location.UnsupportedDatabaseError
+	}
+	return &ProxyWriter{Index: true, t: t, date: date, cols: cols}, nil
+}
+
+// Add adds the inclusive range from-to with the fields of x that databases of the ProxyWriter's type store.
+// IPv4-mapped IPv6 addresses are stored in the IPv4 table.
+func (w *ProxyWriter) Add(from, to netip.Addr, x *ip2location.ProxyRecord) error {
+	return w.blocks.add(from, to, x)
+}
+
+// AddPrefix adds every address of p.
+func (w *ProxyWriter) AddPrefix(p netip.Prefix, x *ip2location.ProxyRecord) error {
+	from, to, err := prefixRange(p)
+	if err != nil {
+		return err
+	}
+	return w.Add(from, to, x)
+}
+
+// AddRange adds a block of a table, keeping its address type.
+// Unlike Add it stores IPv4-mapped ranges in the IPv6 table, where queries never reach them.
+func (w *ProxyWriter) AddRange(r ip2location.Range, x *ip2location.ProxyRecord) error {
+	return w.blocks.addRange(r, x)
+}
+
+// WriteTo writes the database.
+func (w *ProxyWriter) WriteTo(out io.Writer) (int64, error) {
+	h := header{t: uint8(w.t), date: w.date, index: w.Index, cols: len(w.cols)}
+	return w.blocks.write(out, h, func(p *pool, i int, x *ip2location.ProxyRecord) (uint32, error) {
+		if m := w.cols[i]; m != ip2location.ProxyQueryCountryCode|ip2location.ProxyQueryCountryName {
+			return p.string(x.Value(m))
+		}
+		return p.country(x.CountryCode, x.CountryName)
+	})
+}
@@ -0,0 +1,459 @@
+// Package ip2locationwriter builds IP2Location BIN databases that the ip2location package can read.
+package ip2locationwriter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net/netip"
+	"sort"
+	"strconv"
+	"time"
+
+	ip2location "github.com/alxarch/ip2location-go"
+)
+
+var (
+	InvalidRangeError = errors.New("invalid address range")
+	OverlapError      = errors.New("overlapping address ranges")
+	StringLengthError = errors.New("string longer than 255 bytes")
+	CountryCodeError  = errors.New("country code longer than 2 bytes")
+	DatabaseSizeError = errors.New("database larger than 4GB")
+)
+
+const (
+	headerSize = 64
+	indexSize  = 65536 * 8
+)
+
+var (
+	maxIPv4 = ip2location.Uint128{Lo: math.MaxUint32}
+	maxIPv6 = ip2location.Uint128{Hi: math.MaxUint64, Lo: math.MaxUint64}
+	// IPv4-mapped IPv6 addresses are looked up in the IPv4 table
+	mappedFrom = ip2location.Uint128{Lo: 0xffff << 32}
+	mappedTo   = ip2location.Uint128{Lo: 0xffff<<32 | math.MaxUint32}
+)
+
+// block is an inclusive range of addresses with its record.
+type block[R any] struct {
+	from, to ip2location.Uint128
+	rec      *R
+}
+
+// tables holds the blocks of the IPv4 and IPv6 tables.
+type tables[R any] [2][]block[R]
+
+// Writer collects address ranges and writes them as a BIN database.
+// Addresses not covered by any range do not match when queried.
+type Writer struct {
+	// Index writes the first-16-bits index tables that narrow searches.
+	Index bool
+
+	t      ip2location.DBType
+	date   time.Time
+	cols   []ip2location.QueryMode
+	blocks tables[ip2location.Record]
+}
+
+// New creates a Writer for databases of type t released on date.
+func New(t ip2location.DBType, date time.Time) (*Writer, error) {
+	cols := t.Columns()
+	if len(cols) == 0 {
+		return nil, ip2location.UnsupportedDatabaseError
+	}
+	return &Writer{Index: true, t: t, date: date, cols: cols}, nil
+}
+
+// Add adds the inclusive range from-to with the fields of x that databases of the Writer's type store.
+// IPv4-mapped IPv6 addresses are stored in the IPv4 table.
+func (w *Writer) Add(from, to netip.Addr, x *ip2location.Record) error {
+	return w.blocks.add(from, to, x)
+}
+
+// AddPrefix adds every address of p.
+func (w *Writer) AddPrefix(p netip.Prefix, x *ip2location.Record) error {
+	from, to, err := prefixRange(p)
+	if err != nil {
+		return err
+	}
+	return w.Add(from, to, x)
+}
+
+// AddRange adds a block as read from a table with Ranges, keeping its address type.
+// Unlike Add it stores IPv4-mapped ranges in the IPv6 table, where queries never reach them.
+func (w *Writer) AddRange(r ip2location.Range, x *ip2location.Record) error {
+	return w.blocks.addRange(r, x)
+}
+
+// WriteTo writes the database.
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+	h := header{t: uint8(w.t), date: w.date, index: w.Index, cols: len(w.cols)}
+	return w.blocks.write(out, h, func(p *pool, i int, x *ip2location.Record) (uint32, error) {
+		return p.column(w.cols[i], x)
+	})
+}
+
+// add adds the inclusive range from-to, storing IPv4-mapped IPv6 addresses in the IPv4 table.
+func (b *tables[R]) add(from, to netip.Addr, x *R) error {
+	if !from.IsValid() || !to.IsValid() {
+		return InvalidRangeError
+	}
+	lo, hi := number16(from), number16(to)
+	if hi.Less(lo) {
+		return InvalidRangeError
+	}
+	rec := *x
+	if lo.Less(mappedFrom) {
+		b.append(1, lo, min128(hi, mappedFrom.Sub1()), &rec)
+	}
+	if !hi.Less(mappedFrom) && !mappedTo.Less(lo) {
+		b.append(0, unmap(max128(lo, mappedFrom)), unmap(min128(hi, mappedTo)), &rec)
+	}
+	if mappedTo.Less(hi) {
+		b.append(1, max128(lo, mappedTo.Add1()), hi, &rec)
+	}
+	return nil
+}
+
+// prefixRange returns the first and last address of p.
+func prefixRange(p netip.Prefix) (from, to netip.Addr, err error) {
+	if !p.IsValid() {
+		return from, to, InvalidRangeError
+	}
+	p = p.Masked()
+	from = p.Addr()
+	bits := from.BitLen() - p.Bits()
+	last := from.As16()
+	for i := 15; bits > 0; i-- {
+		n := min(bits, 8)
+		last[i] |= byte(1<<n - 1)
+		bits -= n
+	}
+	to = netip.AddrFrom16(last)
+	if from.Is4() {
+		to = to.Unmap()
+	}
+	return from, to, nil
+}
+
+// addRange adds a block to the table of its address type.
+func (b *tables[R]) addRange(r ip2location.Range, x *R) error {
+	var table int
+	var max ip2location.Uint128
+	switch r.Type {
+	case ip2location.IPv4:
+		table, max = 0, maxIPv4
+	case ip2location.IPv6:
+		table, max = 1, maxIPv6
+	default:
+		return InvalidRangeError
+	}
+	if !r.From.Less(r.To) || max.Less(r.To) {
+		return InvalidRangeError
+	}
+	to := r.To
+	if to != max {
+		to = to.Sub1()
+	}
+	rec := *x
+	b.append(table, r.From, to, &rec)
+	return nil
+}
+
+func (b *tables[R]) append(table int, from, to ip2location.Uint128, x *R) {
+	b[table] = append(b[table], block[R]{from, to, x})
+}
+
+// number16 returns the IPv6 number of addr, mapping IPv4 addresses.
+func number16(addr netip.Addr) ip2location.Uint128 {
+	b := addr.As16()
+	return ip2location.Uint128{
+		Hi: binary.BigEndian.Uint64(b[:8]),
+		Lo: binary.BigEndian.Uint64(b[8:]),
+	}
+}
+
+// unmap returns the IPv4 number of an IPv4-mapped address.
+func unmap(n ip2location.Uint128) ip2location.Uint128 {
+	return ip2location.Uint128{Lo: n.Lo &^ mappedFrom.Lo}
+}
+
+func min128(a, b ip2location.Uint128) ip2location.Uint128 {
+	if b.Less(a) {
+		return b
+	}
+	return a
+}
+
+func max128(a, b ip2location.Uint128) ip2location.Uint128 {
+	if a.Less(b) {
+		return b
+	}
+	return a
+}
+
+// row is the start of a block in a table; a nil record marks an unused row.
+type row[R any] struct {
+	from ip2location.Uint128
+	rec  *R
+}
+
+// rows sorts the blocks of a table and fills the gaps between them with unused rows.
+// The rows end with the sentinel row holding max, as IPTo of the last row.
+func rows[R any](blocks []block[R], max ip2location.Uint128) ([]row[R], error) {
+	if len(blocks) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return blocks[i].from.Less(blocks[j].from)
+	})
+	var rows []row[R]
+	next := ip2location.Uint128{}
+	done := false
+	for i, b := range blocks {
+		if i > 0 && !blocks[i-1].to.Less(b.from) {
+			return nil, OverlapError
+		}
+		if !b.from.Less(max) {
+			// max is looked up as max-1 so it can only end a block
+			return nil, InvalidRangeError
+		}
+		if next.Less(b.from) {
+			rows = append(rows, row[R]{from: next})
+		}
+		rows = append(rows, row[R]{from: b.from, rec: b.rec})
+		if done = !b.to.Less(max); !done {
+			next = b.to.Add1()
+		}
+	}
+	if !done {
+		rows = append(rows, row[R]{from: next})
+	}
+	return append(rows, row[R]{from: max}), nil
+}
+
+// find returns the row containing ip.
+func find[R any](rows []row[R], ip ip2location.Uint128) uint32 {
+	i := sort.Search(len(rows), func(i int) bool {
+		return ip.Less(rows[i].from)
+	})
+	return uint32(i - 1)
+}
+
+// index returns the first-16-bits index of a table:
+// for every prefix the rows containing its first and last address.
+func index[R any](rows []row[R], t ip2location.IPType, max ip2location.Uint128) []byte {
+	data := make([]byte, indexSize)
+	for p := uint64(0); p < 65536; p++ {
+		var first, last ip2location.Uint128
+		if t == ip2location.IPv4 {
+			first = ip2location.Uint128{Lo: p << 16}
+			last = ip2location.Uint128{Lo: p<<16 | 0xffff}
+		} else {
+			first = ip2location.Uint128{Hi: p << 48}
+			last = ip2location.Uint128{Hi: p<<48 | (1<<48 - 1), Lo: math.MaxUint64}
+		}
+		if !last.Less(max) {
+			last = max.Sub1()
+		}
+		binary.LittleEndian.PutUint32(data[p*8:], find(rows, first))
+		binary.LittleEndian.PutUint32(data[p*8+4:], find(rows, last))
+	}
+	return data
+}
+
+// pool is the deduplicated string section following the tables.
+type pool struct {
+	base      uint32
+	buf       bytes.Buffer
+	strings   map[string]uint32
+	countries map[[2]string]uint32
+}
+
+func (p *pool) pos() (uint32, error) {
+	pos := uint64(p.base) + uint64(p.buf.Len())
+	if pos > math.MaxUint32 {
+		return 0, DatabaseSizeError
+	}
+	return uint32(pos), nil
+}
+
+func (p *pool) string(s string) (uint32, error) {
+	if pos, ok := p.strings[s]; ok {
+		return pos, nil
+	}
+	if len(s) > 255 {
+		return 0, StringLengthError
+	}
+	pos, err := p.pos()
+	if err != nil {
+		return 0, err
+	}
+	p.buf.WriteByte(byte(len(s)))
+	p.buf.WriteString(s)
+	p.strings[s] = pos
+	return pos, nil
+}
+
+// country stores the code padded to 2 bytes so that the name follows 3 bytes after the code's length.
+func (p *pool) country(code, name string) (uint32, error) {
+	key := [2]string{code, name}
+	if pos, ok := p.countries[key]; ok {
+		return pos, nil
+	}
+	if len(code) > 2 {
+		return 0, CountryCodeError
+	}
+	if len(name) > 255 {
+		return 0, StringLengthError
+	}
+	pos, err := p.pos()
+	if err != nil {
+		return 0, err
+	}
+	p.buf.WriteByte(byte(len(code)))
+	p.buf.WriteString(code)
+	p.buf.Write(make([]byte, 2-len(code)))
+	p.buf.WriteByte(byte(len(name)))
+	p.buf.WriteString(name)
+	p.countries[key] = pos
+	return pos, nil
+}
+
+// column returns the value of a column of x.
+func (p *pool) column(m ip2location.QueryMode, x *ip2location.Record) (uint32, error) {
+	switch m {
+	case ip2location.QueryCountryCode | ip2location.QueryCountryName:
+		return p.country(x.CountryCode, x.CountryName)
+	case ip2location.QueryLatitude:
+		return math.Float32bits(x.Latitude), nil
+	case ip2location.QueryLongitude:
+		return math.Float32bits(x.Longitude), nil
+	case ip2location.QueryElevation:
+		return p.string(strconv.FormatFloat(x.Elevation, 'f', -1, 64))
+	}
+	s, _ := x.Value(m).(string)
+	return p.string(s)
+}
+
+// header holds the header fields of a database.
+type header struct {
+	t     uint8
+	date  time.Time
+	index bool
+	cols  int // columns following IPFrom
+}
+
+// write writes a database with the header h, storing column i of every record as column(p, i, x).
+func (b *tables[R]) write(out io.Writer, h header, column func(p *pool, i int, x *R) (uint32, error)) (int64, error) {
+	var tables [2][]row[R]
+	var err error
+	if tables[0], err = rows(b[0], maxIPv4); err != nil {
+		return 0, err
+	}
+	if tables[1], err = rows(b[1], maxIPv6); err != nil {
+		return 0, err
+	}
+	colsize := h.cols + 1
+	rowSize := [2]uint64{uint64(colsize) * 4, 16 + uint64(colsize-1)*4}
+
+	// offsets are 0-based here and stored 1-based
+	var indexes, addrs [2]uint64
+	size := uint64(headerSize)
+	for i, rows := range tables {
+		if h.index && len(rows) > 0 {
+			indexes[i] = size
+			size += indexSize
+		}
+	}
+	for i, rows := range tables {
+		addrs[i] = size
+		size += uint64(len(rows)) * rowSize[i]
+	}
+	if size > math.MaxUint32 {
+		return 0, DatabaseSizeError
+	}
+	p := &pool{base: uint32(size), strings: map[string]uint32{}, countries: map[[2]string]uint32{}}
+
+	bw := bufio.NewWriter(out)
+	cw := &countWriter{w: bw}
+	head := make([]byte, headerSize)
+	head[0] = h.t
+	head[1] = byte(colsize)
+	head[2] = byte(h.date.Year() - 2000)
+	head[3] = byte(h.date.Month())
+	head[4] = byte(h.date.Day())
+	for i, rows := range tables {
+		count, addr, idx := uint32(0), uint32(0), uint32(0)
+		if len(rows) > 0 {
+			count, addr = uint32(len(rows)-1), uint32(addrs[i]+1)
+			if h.index {
+				idx = uint32(indexes[i] + 1)
+			}
+		}
+		binary.LittleEndian.PutUint32(head[5+i*8:], count)
+		binary.LittleEndian.PutUint32(head[9+i*8:], addr)
+		binary.LittleEndian.PutUint32(head[21+i*4:], idx)
+	}
+	cw.Write(head)
+	for i, rows := range tables {
+		if h.index && len(rows) > 0 {
+			t, max := ip2location.IPv4, maxIPv4
+			if i == 1 {
+				t, max = ip2location.IPv6, maxIPv6
+			}
+			cw.Write(index(rows, t, max))
+		}
+	}
+	buf := make([]byte, rowSize[1])
+	for i, rows := range tables {
+		for _, r := range rows {
+			b := buf[:rowSize[i]]
+			clear(b)
+			n := 4
+			if i == 0 {
+				binary.LittleEndian.PutUint32(b, uint32(r.from.Lo))
+			} else {
+				binary.LittleEndian.PutUint64(b, r.from.Lo)
+				binary.LittleEndian.PutUint64(b[8:], r.from.Hi)
+				n = 16
+			}
+			if r.rec != nil {
+				for c := 0; c < h.cols; c++ {
+					v, err := column(p, c, r.rec)
+					if err != nil {
+						return cw.n, err
+					}
+					binary.LittleEndian.PutUint32(b[n:], v)
+					n += 4
+				}
+			}
+			cw.Write(b)
+		}
+	}
+	cw.Write(p.buf.Bytes())
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, bw.Flush()
+}
+
+// countWriter counts written bytes and keeps the first error.
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
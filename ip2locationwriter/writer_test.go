@@ -0,0 +1,142 @@
+package ip2locationwriter_test
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	ip2loc "github.com/alxarch/ip2location-go"
+	"github.com/alxarch/ip2location-go/ip2locationwriter"
+)
+
+func build(t *testing.T, w *ip2locationwriter.Writer) *ip2loc.DB {
+	t.Helper()
+	buf := bytes.Buffer{}
+	n, err := w.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("Invalid size %d != %d", n, buf.Len())
+	}
+	db, err := ip2loc.NewDB(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func Test_Writer(t *testing.T) {
+	athens := ip2loc.Record{
+		CountryCode: "GR",
+		CountryName: "Greece",
+		Region:      "Attica",
+		City:        "Athens",
+		Latitude:    37.98,
+		Longitude:   23.72,
+		Elevation:   70,
+		ISP:         "OTE",
+		ASN:         "6799",
+	}
+	paris := athens
+	paris.CountryCode, paris.CountryName, paris.City, paris.Region = "FR", "France", "Paris", "Ile-de-France"
+	date := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, index := range []bool{true, false} {
+		w, err := ip2locationwriter.New(ip2loc.DB26, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Index = index
+		if err := w.AddPrefix(netip.MustParsePrefix("1.2.0.0/16"), &athens); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Add(netip.MustParseAddr("255.0.0.0"), netip.MustParseAddr("255.255.255.255"), &paris); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.AddPrefix(netip.MustParsePrefix("2001:db8::/32"), &paris); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.AddPrefix(netip.MustParsePrefix("::ffff:5.0.0.0/104"), &athens); err != nil {
+			t.Fatal(err)
+		}
+		db := build(t, w)
+		meta := db.Meta()
+		if meta.Type() != ip2loc.DB26 || !meta.Date().Equal(date) || meta.HasIndex(ip2loc.IPv4) != index {
+			t.Errorf("Invalid meta %v %v %v", meta.Type(), meta.Date(), meta.HasIndex(ip2loc.IPv4))
+		}
+		for ip, want := range map[string]*ip2loc.Record{
+			"1.2.0.0":          &athens,
+			"1.2.255.255":      &athens,
+			"5.1.2.3":          &athens,
+			"255.255.255.255":  &paris,
+			"2001:db8::1":      &paris,
+			"::ffff:1.2.3.4":   &athens,
+			"1.1.255.255":      nil,
+			"1.3.0.0":          nil,
+			"0.0.0.0":          nil,
+			"2001:db9::":       nil,
+			"ffff::":           nil,
+			"254.255.255.255":  nil,
+			"2001:db8:ffff::1": &paris,
+		} {
+			x := ip2loc.Record{}
+			err := db.Query(ip, &x, ip2loc.QueryAll)
+			if want == nil {
+				if !errors.Is(err, ip2loc.NoMatchError) {
+					t.Errorf("%s: expected no match, got %v", ip, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: %s", ip, err)
+				continue
+			}
+			x.Mode = 0
+			if x != *want {
+				t.Errorf("%s: invalid record %v", ip, x)
+			}
+		}
+		count := 0
+		for range db.Ranges(ip2loc.IPv4, 0).All() {
+			count++
+		}
+		if count != 3 {
+			t.Errorf("Invalid IPv4 ranges %d", count)
+		}
+	}
+}
+
+func Test_WriterCSV(t *testing.T) {
+	w, err := ip2locationwriter.New(ip2loc.DB3, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	csv := `"0","16777215","-","-","-","-"
+"16777216","16777471","AU","Australia","Queensland","Brisbane"
+"16777472","4294967295","CN","China","Fujian","Fuzhou"
+`
+	if err := w.ReadCSV(strings.NewReader(csv), ip2loc.IPv4); err != nil {
+		t.Fatal(err)
+	}
+	db := build(t, w)
+	x := ip2loc.Record{}
+	if err := db.Query("1.0.0.1", &x, ip2loc.QueryAll); err != nil {
+		t.Fatal(err)
+	}
+	if x.CountryCode != "AU" || x.CountryName != "Australia" || x.City != "Brisbane" {
+		t.Errorf("Invalid record %v", x)
+	}
+	if err := db.Query("0.0.0.1", &x, ip2loc.QueryCountryCode); err != nil || x.CountryCode != "-" {
+		t.Errorf("Invalid record %v %v", x, err)
+	}
+
+	w, _ = ip2locationwriter.New(ip2loc.DB1, time.Now())
+	w.AddPrefix(netip.MustParsePrefix("10.0.0.0/8"), &ip2loc.Record{CountryCode: "GR"})
+	w.AddPrefix(netip.MustParsePrefix("10.1.0.0/16"), &ip2loc.Record{CountryCode: "FR"})
+	if _, err := w.WriteTo(&bytes.Buffer{}); !errors.Is(err, ip2locationwriter.OverlapError) {
+		t.Errorf("Expected overlap error, got %v", err)
+	}
+}
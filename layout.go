@@ -0,0 +1,102 @@
+package ip2location
+
+import "strconv"
+
+// queryCountry is the single column holding both country code and name.
+const queryCountry = QueryCountryCode | QueryCountryName
+
+var (
+	colsGeo       = []QueryMode{queryCountry, QueryRegion, QueryCity}
+	colsLocation  = cols(colsGeo, QueryLatitude, QueryLongitude)
+	colsTimeZone  = cols(colsLocation, QueryZipCode, QueryTimeZone)
+	colsNetwork   = cols(colsTimeZone, QueryISP, QueryDomain, QueryNetSpeed)
+	colsAreaCode  = cols(colsNetwork, QueryIDDCode, QueryAreaCode)
+	colsWeather   = cols(colsAreaCode, QueryWeatherStationCode, QueryWeatherStationName)
+	colsMobile    = cols(colsWeather, QueryMCC, QueryMNC, QueryMobileBrand)
+	colsElevation = cols(colsMobile, QueryElevation)
+	colsUsageType = cols(colsElevation, QueryUsageType)
+	colsCategory  = cols(colsUsageType, QueryAddressType, QueryCategory)
+)
+
+// dbLayouts lists the columns following IPFrom in each database type, in file order.
+// Adding a product type only requires appending its column list here.
+var dbLayouts = [maxdb][]QueryMode{
+	DB1:  {queryCountry},
+	DB2:  {queryCountry, QueryISP},
+	DB3:  colsGeo,
+	DB4:  cols(colsGeo, QueryISP),
+	DB5:  colsLocation,
+	DB6:  cols(colsLocation, QueryISP),
+	DB7:  cols(colsGeo, QueryISP, QueryDomain),
+	DB8:  cols(colsLocation, QueryISP, QueryDomain),
+	DB9:  cols(colsLocation, QueryZipCode),
+	DB10: cols(colsLocation, QueryZipCode, QueryISP, QueryDomain),
+	DB11: colsTimeZone,
+	DB12: cols(colsTimeZone, QueryISP, QueryDomain),
+	DB13: cols(colsLocation, QueryTimeZone, QueryNetSpeed),
+	DB14: colsNetwork,
+	DB15: cols(colsTimeZone, QueryIDDCode, QueryAreaCode),
+	DB16: colsAreaCode,
+	DB17: cols(colsLocation, QueryTimeZone, QueryNetSpeed, QueryWeatherStationCode, QueryWeatherStationName),
+	DB18: colsWeather,
+	DB19: cols(colsLocation, QueryISP, QueryDomain, QueryMCC, QueryMNC, QueryMobileBrand),
+	DB20: colsMobile,
+	DB21: cols(colsTimeZone, QueryIDDCode, QueryAreaCode, QueryElevation),
+	DB22: colsElevation,
+	DB23: cols(colsLocation, QueryISP, QueryDomain, QueryMCC, QueryMNC, QueryMobileBrand, QueryUsageType),
+	DB24: colsUsageType,
+	DB25: colsCategory,
+	DB26: cols(colsCategory, QueryDistrict, QueryASN, QueryAS),
+}
+
+func (t DBType) String() string {
+	return "DB" + strconv.Itoa(int(t))
+}
+
+// Modes returns the fields stored in databases of type t.
+func (t DBType) Modes() QueryMode {
+	var mode QueryMode
+	if t < maxdb {
+		for _, m := range dbLayouts[t] {
+			mode |= m
+		}
+	}
+	return mode
+}
+
+// Columns returns the fields of each column following IPFrom in databases of type t, in file order.
+// The first column holds both country code and name.
+func (t DBType) Columns() []QueryMode {
+	if t >= maxdb {
+		return nil
+	}
+	return cols(dbLayouts[t])
+}
+
+// cols returns a copy of base with extra columns appended.
+func cols[M ~uint32](base []M, extra ...M) []M {
+	c := make([]M, 0, len(base)+len(extra))
+	return append(append(c, base...), extra...)
+}
+
+// column is a field stored at a fixed byte offset after IPFrom.
+type column[M ~uint32] struct {
+	mode   M
+	offset uint32
+}
+
+// layoutColumns returns the columns of a layout.
+// Columns holding more than one field, like country code and name, are returned as separate entries.
+func layoutColumns[M ~uint32](layout []M) (columns []column[M], mode M) {
+	for i, m := range layout {
+		// since both IPv4 and IPv6 use 4 bytes for the below columns, can just do it once here
+		offset := uint32(i+1) << 2
+		for f := M(1); f != 0 && f <= m; f <<= 1 {
+			if m&f != 0 {
+				columns = append(columns, column[M]{f, offset})
+				mode |= f
+			}
+		}
+	}
+	return
+}
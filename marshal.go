@@ -0,0 +1,384 @@
+package ip2location
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// format returns the text of a single field mode.
+func (x *Record) format(m QueryMode) string {
+	switch m {
+	case QueryLatitude:
+		return strconv.FormatFloat(float64(x.Latitude), 'f', -1, 32)
+	case QueryLongitude:
+		return strconv.FormatFloat(float64(x.Longitude), 'f', -1, 32)
+	case QueryElevation:
+		return strconv.FormatFloat(x.Elevation, 'f', -1, 64)
+	}
+	s, _ := x.Value(m).(string)
+	return s
+}
+
+// Format returns the text of a single field mode as MarshalText and CSVWriter write it,
+// or an empty string if the field is not populated.
+func (x *Record) Format(m QueryMode) string {
+	if x.Mode&m == 0 {
+		return ""
+	}
+	return x.format(m)
+}
+
+// finite reports whether a single numeric field mode holds a finite number.
+func (x *Record) finite(m QueryMode) bool {
+	var f float64
+	switch m {
+	case QueryLatitude:
+		f = float64(x.Latitude)
+	case QueryLongitude:
+		f = float64(x.Longitude)
+	case QueryElevation:
+		f = x.Elevation
+	}
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// Set sets a single field mode from its text, as written by MarshalText, and adds it to Mode.
+func (x *Record) Set(m QueryMode, s string) error {
+	return x.parse(m, s)
+}
+
+// parse sets a single field mode from its text.
+func (x *Record) parse(m QueryMode, s string) error {
+	switch m {
+	case QueryLatitude, QueryLongitude:
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", m.Name(), s)
+		}
+		if m == QueryLatitude {
+			x.Latitude = float32(f)
+		} else {
+			x.Longitude = float32(f)
+		}
+	case QueryElevation:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", m.Name(), s)
+		}
+		x.Elevation = f
+	default:
+		p := x.field(m)
+		if p == nil {
+			return fmt.Errorf("unknown field %q", m.String())
+		}
+		*p = s
+	}
+	x.Mode |= m
+	return nil
+}
+
+// field returns a pointer to a single string field mode.
+func (x *Record) field(m QueryMode) *string {
+	switch m {
+	case QueryCountryCode:
+		return &x.CountryCode
+	case QueryCountryName:
+		return &x.CountryName
+	case QueryRegion:
+		return &x.Region
+	case QueryCity:
+		return &x.City
+	case QueryISP:
+		return &x.ISP
+	case QueryDomain:
+		return &x.Domain
+	case QueryZipCode:
+		return &x.ZipCode
+	case QueryTimeZone:
+		return &x.Timezone
+	case QueryNetSpeed:
+		return &x.NetSpeed
+	case QueryIDDCode:
+		return &x.IDDCode
+	case QueryAreaCode:
+		return &x.Areacode
+	case QueryWeatherStationCode:
+		return &x.WeatherStationCode
+	case QueryWeatherStationName:
+		return &x.WeatherStationName
+	case QueryMCC:
+		return &x.MCC
+	case QueryMNC:
+		return &x.MNC
+	case QueryMobileBrand:
+		return &x.MobileBrand
+	case QueryUsageType:
+		return &x.UsageType
+	case QueryAddressType:
+		return &x.AddressType
+	case QueryCategory:
+		return &x.Category
+	case QueryDistrict:
+		return &x.District
+	case QueryASN:
+		return &x.ASN
+	case QueryAS:
+		return &x.AS
+	}
+	return nil
+}
+
+// MarshalJSON writes the populated fields as an object with the column names as keys.
+// Latitude, longitude and elevation are numbers, or null if they are not finite; all other fields are strings.
+func (x Record) MarshalJSON() ([]byte, error) {
+	b := bytes.Buffer{}
+	b.WriteByte('{')
+	if err := x.writeJSON(&b, false); err != nil {
+		return nil, err
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// MarshalJSONWithIP is like MarshalJSON with the looked up address as an "ip" key ahead of the fields.
+func (x *Record) MarshalJSONWithIP(ip string) ([]byte, error) {
+	b := bytes.Buffer{}
+	s, err := json.Marshal(ip)
+	if err != nil {
+		return nil, err
+	}
+	b.WriteString(`{"ip":`)
+	b.Write(s)
+	if err := x.writeJSON(&b, true); err != nil {
+		return nil, err
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// writeJSON writes the populated fields as object members, after a comma if more follow earlier ones.
+func (x *Record) writeJSON(b *bytes.Buffer, more bool) error {
+	for _, m := range x.Mode.Fields() {
+		if more {
+			b.WriteByte(',')
+		}
+		more = true
+		b.WriteByte('"')
+		b.WriteString(m.Name())
+		b.WriteString(`":`)
+		switch m {
+		case QueryLatitude, QueryLongitude, QueryElevation:
+			if x.finite(m) {
+				b.WriteString(x.format(m))
+			} else {
+				b.WriteString("null")
+			}
+		default:
+			s, err := json.Marshal(x.format(m))
+			if err != nil {
+				return err
+			}
+			b.Write(s)
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON reads the fields written by MarshalJSON and adds them to Mode.
+// Unknown keys and null numbers are ignored.
+func (x *Record) UnmarshalJSON(data []byte) error {
+	obj := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	for _, f := range queryFields {
+		raw, ok := obj[f.name]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		var err error
+		switch f.mode {
+		case QueryLatitude, QueryLongitude, QueryElevation:
+			var n json.Number
+			if err = json.Unmarshal(raw, &n); err == nil {
+				err = x.parse(f.mode, n.String())
+			}
+		default:
+			var s string
+			if err = json.Unmarshal(raw, &s); err == nil {
+				err = x.parse(f.mode, s)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalText writes the populated fields as space separated name=value pairs.
+// Values that are empty or contain spaces, quotes or equal signs are quoted.
+func (x Record) MarshalText() ([]byte, error) {
+	b := bytes.Buffer{}
+	for i, m := range x.Mode.Fields() {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(m.Name())
+		b.WriteByte('=')
+		if v := x.format(m); needsQuote(v) {
+			b.WriteString(strconv.Quote(v))
+		} else {
+			b.WriteString(v)
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func needsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, c := range s {
+		if c == '"' || c == '=' || c == '\\' || unicode.IsSpace(c) || !unicode.IsPrint(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalText reads the pairs written by MarshalText and adds them to Mode.
+func (x *Record) UnmarshalText(text []byte) error {
+	s := string(text)
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			return nil
+		}
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("invalid field %q", s)
+		}
+		m := queryModeByName(name)
+		if m == 0 {
+			return fmt.Errorf("unknown field %q", name)
+		}
+		var v string
+		if strings.HasPrefix(rest, `"`) {
+			q, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", name, rest)
+			}
+			v, _ = strconv.Unquote(q)
+			s = rest[len(q):]
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end == -1 {
+				end = len(rest)
+			}
+			v, s = rest[:end], rest[end:]
+		}
+		if err := x.parse(m, v); err != nil {
+			return err
+		}
+	}
+}
+
+// appendFields appends the text of fields to dst, leaving fields that are not populated empty.
+func (x *Record) appendFields(dst []string, fields []QueryMode) []string {
+	for _, m := range fields {
+		dst = append(dst, x.Format(m))
+	}
+	return dst
+}
+
+// CSVWriter writes records as CSV rows with a column for every field of its mode in CSV column order.
+type CSVWriter struct {
+	w      *csv.Writer
+	fields []QueryMode
+	row    []string
+}
+
+func NewCSVWriter(w io.Writer, mode QueryMode) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w), fields: mode.Fields()}
+}
+
+// WriteHeader writes the column names.
+func (c *CSVWriter) WriteHeader() error {
+	c.row = c.row[:0]
+	for _, m := range c.fields {
+		c.row = append(c.row, m.Name())
+	}
+	return c.w.Write(c.row)
+}
+
+// Write writes a row for x. Columns for fields that are not populated in x are empty.
+func (c *CSVWriter) Write(x *Record) error {
+	c.row = x.appendFields(c.row[:0], c.fields)
+	return c.w.Write(c.row)
+}
+
+// Flush writes buffered rows and reports any write error.
+func (c *CSVWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// CSVReader reads records written by CSVWriter.
+// Empty columns are not populated, so fields holding empty strings read back without their Mode.
+type CSVReader struct {
+	r      *csv.Reader
+	fields []QueryMode
+}
+
+// NewCSVReader creates a reader for rows with the columns of mode.
+// Use ReadHeader to take the columns from a header row instead.
+func NewCSVReader(r io.Reader, mode QueryMode) *CSVReader {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	return &CSVReader{r: cr, fields: mode.Fields()}
+}
+
+// ReadHeader reads a row of column names and uses them for the following rows.
+func (c *CSVReader) ReadHeader() error {
+	row, err := c.r.Read()
+	if err != nil {
+		return err
+	}
+	fields := make([]QueryMode, len(row))
+	for i, name := range row {
+		if fields[i] = queryModeByName(name); fields[i] == 0 {
+			return fmt.Errorf("unknown field %q", name)
+		}
+	}
+	c.fields = fields
+	return nil
+}
+
+// Read reads the next row into x and adds its non-empty columns to Mode. It returns io.EOF after the last row.
+func (c *CSVReader) Read(x *Record) error {
+	row, err := c.r.Read()
+	if err != nil {
+		return err
+	}
+	if len(row) != len(c.fields) {
+		return fmt.Errorf("invalid row with %d columns", len(row))
+	}
+	for i, m := range c.fields {
+		if row[i] == "" {
+			// fields that are not populated are written empty
+			continue
+		}
+		if err := x.parse(m, row[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package ip2location_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"testing"
+
+	ip2loc "github.com/alxarch/ip2location-go"
+)
+
+var marshalRecords = []ip2loc.Record{
+	{},
+	{CountryCode: "US", CountryName: "United States of America", Mode: ip2loc.QueryCountryCode | ip2loc.QueryCountryName},
+	{City: "", Region: `Quote "=\ region`, Latitude: 37.405992, Longitude: -122.078515, Elevation: 32.5,
+		Mode: ip2loc.QueryCity | ip2loc.QueryRegion | ip2loc.QueryLatitude | ip2loc.QueryLongitude | ip2loc.QueryElevation},
+	{CountryCode: "GR", Timezone: "+02:00", AS: "AS Ελλάδα", ASN: "1241", Mode: ip2loc.QueryAll},
+}
+
+func Test_RecordMarshal(t *testing.T) {
+	x := ip2loc.Record{CountryCode: "US", City: "Mountain View", ISP: "not queried", Latitude: 1.5, Mode: ip2loc.QueryCountryCode | ip2loc.QueryCity | ip2loc.QueryLatitude}
+	data, err := json.Marshal(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); s != `{"country_code":"US","city_name":"Mountain View","latitude":1.5}` {
+		t.Errorf("Invalid JSON %s", s)
+	}
+	if s := x.String(); s != `country_code=US city_name="Mountain View" latitude=1.5` {
+		t.Errorf("Invalid text %s", s)
+	}
+	if data, err := x.MarshalJSONWithIP("8.8.8.8"); err != nil || string(data) != `{"ip":"8.8.8.8","country_code":"US","city_name":"Mountain View","latitude":1.5}` {
+		t.Errorf("Invalid JSON with IP %s %v", data, err)
+	}
+	if data, err := (&ip2loc.Record{}).MarshalJSONWithIP("8.8.8.8"); err != nil || string(data) != `{"ip":"8.8.8.8"}` {
+		t.Errorf("Invalid JSON with IP %s %v", data, err)
+	}
+	if x.Format(ip2loc.QueryLatitude) != "1.5" || x.Format(ip2loc.QueryISP) != "" {
+		t.Errorf("Invalid field text %q %q", x.Format(ip2loc.QueryLatitude), x.Format(ip2loc.QueryISP))
+	}
+
+	// numbers that are not finite are null
+	x = ip2loc.Record{Latitude: float32(math.NaN()), Longitude: float32(math.Inf(1)), Elevation: math.Inf(-1),
+		Mode: ip2loc.QueryLatitude | ip2loc.QueryLongitude | ip2loc.QueryElevation}
+	data, err = json.Marshal(&x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); s != `{"latitude":null,"longitude":null,"elevation":null}` {
+		t.Errorf("Invalid JSON %s", s)
+	}
+	if y := (ip2loc.Record{}); json.Unmarshal(data, &y) != nil || y != (ip2loc.Record{}) {
+		t.Errorf("Invalid null numbers %+v", y)
+	}
+
+	for _, x := range marshalRecords {
+		data, err := json.Marshal(&x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		y := ip2loc.Record{}
+		if err := json.Unmarshal(data, &y); err != nil {
+			t.Fatal(err)
+		}
+		if y != x {
+			t.Errorf("JSON round trip %s: %+v", data, y)
+		}
+
+		text, err := x.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		y = ip2loc.Record{}
+		if err := y.UnmarshalText(text); err != nil {
+			t.Fatal(err)
+		}
+		if y != x {
+			t.Errorf("Text round trip %s: %+v", text, y)
+		}
+	}
+	if err := (&ip2loc.Record{}).UnmarshalText([]byte("city_name=x nope=1")); err == nil {
+		t.Error("Unknown field accepted")
+	}
+}
+
+func Test_RecordCSV(t *testing.T) {
+	b := bytes.Buffer{}
+	w := ip2loc.NewCSVWriter(&b, ip2loc.QueryAll)
+	if err := w.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range marshalRecords {
+		if err := w.Write(&marshalRecords[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	r := ip2loc.NewCSVReader(&b, 0)
+	if err := r.ReadHeader(); err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range marshalRecords {
+		y := ip2loc.Record{}
+		if err := r.Read(&y); err != nil {
+			t.Fatal(err)
+		}
+		// empty values read as not populated
+		want := x
+		for _, m := range x.Mode.Fields() {
+			if x.Value(m) == "" {
+				want.Mode &^= m
+			}
+		}
+		if y != want {
+			t.Errorf("CSV round trip %+v: %+v", x, y)
+		}
+	}
+	if err := r.Read(&ip2loc.Record{}); err != io.EOF {
+		t.Errorf("Read after last row %v", err)
+	}
+}
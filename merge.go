@@ -0,0 +1,202 @@
+package ip2location
+
+import (
+	"context"
+	"sort"
+)
+
+// MergeStrategy returns the indexes of dbs in the order they are preferred for a single field.
+// Databases left out never supply the field; indexes out of range or listed more than once are ignored.
+type MergeStrategy func(dbs []IP2LocationDB, field QueryMode) []int
+
+// Provenance maps every field of a merged query to the index of the database that supplied it.
+type Provenance map[QueryMode]int
+
+// metaReader is implemented by databases that expose their header.
+type metaReader interface {
+	Meta() DBMeta
+}
+
+func indexes(n int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+// FirstWins prefers databases in order.
+func FirstWins(dbs []IP2LocationDB, field QueryMode) []int {
+	return indexes(len(dbs))
+}
+
+// LastWins prefers databases in reverse order, like MultiDB.
+func LastWins(dbs []IP2LocationDB, field QueryMode) []int {
+	order := indexes(len(dbs))
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	return order
+}
+
+// byMeta orders databases with a header by less, followed by the rest in order.
+func byMeta(dbs []IP2LocationDB, less func(a, b *DBMeta) bool) []int {
+	metas := make([]*DBMeta, len(dbs))
+	for i, db := range dbs {
+		if db, ok := db.(metaReader); ok {
+			m := db.Meta()
+			metas[i] = &m
+		}
+	}
+	order := indexes(len(dbs))
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := metas[order[i]], metas[order[j]]
+		return a != nil && (b == nil || less(a, b))
+	})
+	return order
+}
+
+// NewestWins prefers databases with the latest release date.
+func NewestWins(dbs []IP2LocationDB, field QueryMode) []int {
+	return byMeta(dbs, func(a, b *DBMeta) bool {
+		return a.Date().After(b.Date())
+	})
+}
+
+// HighestTypeWins prefers databases with the highest DBType.
+func HighestTypeWins(dbs []IP2LocationDB, field QueryMode) []int {
+	return byMeta(dbs, func(a, b *DBMeta) bool {
+		return a.Type() > b.Type()
+	})
+}
+
+// FieldPriority prefers the databases listed for a field, followed by the rest in fallback order.
+// Keys may combine several fields but must not overlap.
+func FieldPriority(priority map[QueryMode][]int, fallback MergeStrategy) MergeStrategy {
+	return func(dbs []IP2LocationDB, field QueryMode) []int {
+		var order []int
+		for fields, indexes := range priority {
+			if fields&field != 0 {
+				order = append(order, indexes...)
+				break
+			}
+		}
+		for _, i := range fallback(dbs, field) {
+			listed := false
+			for _, j := range order {
+				listed = listed || i == j
+			}
+			if !listed {
+				order = append(order, i)
+			}
+		}
+		return order
+	}
+}
+
+// MergeDB queries each of its databases into a separate Record and takes every field
+// from the database its Strategy prefers among those that have it.
+// Fields count as supplied when they are set in the Mode of a database's Record.
+// A nil Strategy is LastWins.
+// MergeDB values built by NewMergeDB ask the Strategy once per field;
+// others ask it on every query.
+// It is safe for concurrent use if all its databases are.
+type MergeDB struct {
+	MultiDB
+	Strategy MergeStrategy
+
+	orders map[QueryMode][]int
+}
+
+// NewMergeDB creates a MergeDB of dbs that orders them by strategy once for every field.
+// The databases and the strategy must not change afterwards.
+func NewMergeDB(strategy MergeStrategy, dbs ...IP2LocationDB) *MergeDB {
+	m := &MergeDB{MultiDB: dbs, Strategy: strategy}
+	orders := make(map[QueryMode][]int)
+	for _, f := range QueryAll.Fields() {
+		orders[f] = m.order(f)
+	}
+	m.orders = orders
+	return m
+}
+
+// order returns the indexes of the databases in the order they are preferred for field.
+func (m *MergeDB) order(field QueryMode) []int {
+	if order, ok := m.orders[field]; ok {
+		return order
+	}
+	strategy := m.Strategy
+	if strategy == nil {
+		strategy = LastWins
+	}
+	return validOrder(strategy(m.MultiDB, field), len(m.MultiDB))
+}
+
+// validOrder drops the indexes of order that are out of range for n databases or repeat earlier ones.
+func validOrder(order []int, n int) []int {
+	seen := make([]bool, n)
+	valid := make([]int, 0, len(order))
+	for _, i := range order {
+		if 0 <= i && i < n && !seen[i] {
+			seen[i] = true
+			valid = append(valid, i)
+		}
+	}
+	return valid
+}
+
+func (m *MergeDB) Query(ip string, r *Record, mode QueryMode) error {
+	return m.QueryContext(context.Background(), ip, r, mode)
+}
+
+// QueryContext is like Query but stops when ctx is done.
+func (m *MergeDB) QueryContext(ctx context.Context, ip string, r *Record, mode QueryMode) error {
+	return m.query(ctx, ip, r, mode, nil)
+}
+
+// QueryProvenance is like QueryContext but also reports which database supplied each field.
+func (m *MergeDB) QueryProvenance(ctx context.Context, ip string, r *Record, mode QueryMode) (Provenance, error) {
+	p := Provenance{}
+	if err := m.query(ctx, ip, r, mode, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (m *MergeDB) query(ctx context.Context, ip string, r *Record, mode QueryMode, p Provenance) error {
+	mode, err := m.strict(ip, mode)
+	if err != nil {
+		return err
+	}
+	records := make([]Record, len(m.MultiDB))
+	matches := 0
+	var lasterr error
+	for i, db := range m.MultiDB {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := db.QueryContext(ctx, ip, &records[i], mode); err != nil {
+			if !skippable(err) {
+				return err
+			}
+			lasterr = err
+		} else {
+			matches++
+		}
+	}
+	if matches == 0 {
+		return lasterr
+	}
+	for _, f := range mode.Fields() {
+		for _, i := range m.order(f) {
+			if records[i].Mode&f != 0 {
+				r.copyFrom(&records[i], f)
+				if p != nil {
+					p[f] = i
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package ip2location_test
+
+import (
+	"context"
+	"testing"
+
+	ip2loc "github.com/alxarch/ip2location-go"
+)
+
+// fixedDB answers every query with the fields of its record.
+type fixedDB ip2loc.Record
+
+func (db *fixedDB) Query(ip string, x *ip2loc.Record, mode ip2loc.QueryMode) error {
+	return db.QueryContext(context.Background(), ip, x, mode)
+}
+
+func (db *fixedDB) QueryContext(_ context.Context, ip string, x *ip2loc.Record, mode ip2loc.QueryMode) error {
+	rec := (*ip2loc.Record)(db)
+	if mode&rec.Mode == 0 {
+		return ip2loc.NotSupportedError
+	}
+	y := *rec
+	y.Mode &= mode
+	*x = y
+	return nil
+}
+
+func (db *fixedDB) SupportedModes() ip2loc.QueryMode {
+	return db.Mode
+}
+
+func (db *fixedDB) Close() error {
+	return nil
+}
+
+func Test_MergeDB(t *testing.T) {
+	city := &fixedDB{City: "Athens", Region: "Attica", Mode: ip2loc.QueryCity | ip2loc.QueryRegion}
+	isp := &fixedDB{City: "Athina", ISP: "OTE", Mode: ip2loc.QueryCity | ip2loc.QueryISP}
+	dbs := ip2loc.MultiDB{city, isp}
+
+	for _, tc := range []struct {
+		strategy ip2loc.MergeStrategy
+		city     string
+		prov     ip2loc.Provenance
+	}{
+		{nil, "Athina", ip2loc.Provenance{ip2loc.QueryCity: 1, ip2loc.QueryRegion: 0, ip2loc.QueryISP: 1}},
+		{ip2loc.LastWins, "Athina", ip2loc.Provenance{ip2loc.QueryCity: 1, ip2loc.QueryRegion: 0, ip2loc.QueryISP: 1}},
+		{ip2loc.FirstWins, "Athens", ip2loc.Provenance{ip2loc.QueryCity: 0, ip2loc.QueryRegion: 0, ip2loc.QueryISP: 1}},
+		{ip2loc.FieldPriority(map[ip2loc.QueryMode][]int{ip2loc.QueryCity: {0}}, ip2loc.LastWins), "Athens", ip2loc.Provenance{ip2loc.QueryCity: 0, ip2loc.QueryRegion: 0, ip2loc.QueryISP: 1}},
+		// out of range and repeated indexes are ignored
+		{ip2loc.FieldPriority(map[ip2loc.QueryMode][]int{ip2loc.QueryCity: {5}}, ip2loc.FirstWins), "Athens", ip2loc.Provenance{ip2loc.QueryCity: 0, ip2loc.QueryRegion: 0, ip2loc.QueryISP: 1}},
+		{ip2loc.FieldPriority(map[ip2loc.QueryMode][]int{ip2loc.QueryCity: {-1, 1, 1, 2}}, ip2loc.FirstWins), "Athina", ip2loc.Provenance{ip2loc.QueryCity: 1, ip2loc.QueryRegion: 0, ip2loc.QueryISP: 1}},
+		// databases without a header come last
+		{ip2loc.NewestWins, "Athens", ip2loc.Provenance{ip2loc.QueryCity: 0, ip2loc.QueryRegion: 0, ip2loc.QueryISP: 1}},
+	} {
+		for _, m := range []*ip2loc.MergeDB{
+			{MultiDB: dbs, Strategy: tc.strategy},
+			ip2loc.NewMergeDB(tc.strategy, dbs...),
+		} {
+			x := ip2loc.Record{}
+			prov, err := m.QueryProvenance(context.Background(), "8.8.8.8", &x, ip2loc.QueryAll)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if x.City != tc.city || x.Region != "Attica" || x.ISP != "OTE" || x.Mode != ip2loc.QueryCity|ip2loc.QueryRegion|ip2loc.QueryISP {
+				t.Errorf("Invalid merge %+v", x)
+			}
+			if len(prov) != len(tc.prov) {
+				t.Errorf("Invalid provenance %v", prov)
+			}
+			for f, i := range tc.prov {
+				if prov[f] != i {
+					t.Errorf("Invalid provenance %v", prov)
+				}
+			}
+		}
+	}
+
+	// NewMergeDB asks the strategy once per field
+	calls := 0
+	counted := func(dbs []ip2loc.IP2LocationDB, field ip2loc.QueryMode) []int {
+		calls++
+		return ip2loc.FirstWins(dbs, field)
+	}
+	m := ip2loc.NewMergeDB(counted, dbs...)
+	fields := len(ip2loc.QueryAll.Fields())
+	for i := 0; i < 3; i++ {
+		if err := m.Query("8.8.8.8", &ip2loc.Record{}, ip2loc.QueryAll); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != fields {
+		t.Errorf("Strategy called %d times for %d fields", calls, fields)
+	}
+
+	m = ip2loc.NewMergeDB(ip2loc.FirstWins, dbs...)
+	if err := m.Query("8.8.8.8", &ip2loc.Record{}, ip2loc.QueryCity|ip2loc.QueryDomain|ip2loc.QueryStrict); err == nil {
+		t.Error("Strict query for missing field succeeded")
+	}
+	x := ip2loc.Record{}
+	if err := m.Query("8.8.8.8", &x, ip2loc.QueryRegion); err != nil || x.Mode != ip2loc.QueryRegion {
+		t.Errorf("Query with unsupported databases %+v %v", x, err)
+	}
+	if err := m.Query("8.8.8.8", &x, ip2loc.QueryDomain); err == nil {
+		t.Error("Query for missing field succeeded")
+	}
+}
+
+func Test_MergeDBMeta(t *testing.T) {
+	db, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	supported := db.SupportedModes()
+	other := &fixedDB{Mode: supported}
+	for _, strategy := range []ip2loc.MergeStrategy{ip2loc.NewestWins, ip2loc.HighestTypeWins} {
+		m := ip2loc.NewMergeDB(strategy, other, db)
+		prov, err := m.QueryProvenance(context.Background(), "8.8.8.8", &ip2loc.Record{}, supported)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for f, i := range prov {
+			if i != 1 {
+				t.Errorf("%s supplied by %d", f, i)
+			}
+		}
+	}
+}
@@ -1,20 +1,17 @@
 package ip2location
 
 import (
+	"encoding/json"
 	"io"
-	"math/big"
+	"math"
 	"time"
 )
 
 var (
-	max_ipv4_range = big.NewInt(4294967295)
-	max_ipv6_range = big.NewInt(0)
+	max_ipv4_range = Uint128{0, math.MaxUint32}
+	max_ipv6_range = Uint128{math.MaxUint64, math.MaxUint64}
 )
 
-func init() {
-	max_ipv6_range.SetString("340282366920938463463374607431768211455", 10)
-}
-
 type DBMeta struct {
 	dbtype      DBType
 	colsize     uint8
@@ -27,24 +24,29 @@ type DBMeta struct {
 	ipv6index   uint32
 	ipv4colsize uint32
 	ipv6colsize uint32
-	ipv4bigidx  *big.Int
-	ipv6bigidx  *big.Int
+	member      string
 }
 
 func (m *DBMeta) Type() DBType {
 	return m.dbtype
 }
+
+// Member returns the name of the archive member the database was read from, if any.
+func (m *DBMeta) Member() string {
+	return m.member
+}
+
 func (m *DBMeta) HasIndex(t IPType) bool {
 	switch t {
 	case IPv4:
 		return m.ipv4index > 0
 	case IPv6:
-		return m.ipv4index > 0
+		return m.ipv6index > 0
 	default:
 		return false
 	}
 }
-func (m *DBMeta) Indexes(t IPType) (start, end, colsize uint32, max *big.Int) {
+func (m *DBMeta) Indexes(t IPType) (start, end, colsize uint32, max Uint128) {
 	switch t {
 	case IPv4:
 		start = m.ipv4addr
@@ -70,10 +72,45 @@ func (m *DBMeta) Has(t IPType) bool {
 	}
 
 }
+
+// Count returns the number of blocks in table t.
+func (m *DBMeta) Count(t IPType) uint32 {
+	switch t {
+	case IPv4:
+		return m.ipv4count
+	case IPv6:
+		return m.ipv6count
+	default:
+		return 0
+	}
+}
 func (m *DBMeta) Date() time.Time {
 	return m.date
 }
 
+// MarshalJSON describes the database header.
+func (m DBMeta) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type      string `json:"type"`
+		Date      string `json:"date"`
+		IPv4Count uint32 `json:"ipv4_count"`
+		IPv6Count uint32 `json:"ipv6_count"`
+		IPv4Index bool   `json:"ipv4_index"`
+		IPv6Index bool   `json:"ipv6_index"`
+		Fields    string `json:"fields"`
+		Member    string `json:"member,omitempty"`
+	}{
+		Type:      m.dbtype.String(),
+		Date:      m.date.Format("2006-01-02"),
+		IPv4Count: m.ipv4count,
+		IPv6Count: m.ipv6count,
+		IPv4Index: m.HasIndex(IPv4),
+		IPv6Index: m.HasIndex(IPv6),
+		Fields:    m.dbtype.Modes().String(),
+		Member:    m.member,
+	})
+}
+
 func readDbType(r io.ReaderAt) (DBType, error) {
 	t, err := readUint8(r, 1)
 	return DBType(t), err
@@ -101,6 +138,7 @@ func (m *DBMeta) Read(r io.ReaderAt) (err error) {
 	}
 
 	if m.colsize, err = readUint8(r, 2); err != nil {
+		return
 	}
 	if m.ipv4count, err = readUint32(r, 6); err != nil {
 		return
@@ -120,8 +158,6 @@ func (m *DBMeta) Read(r io.ReaderAt) (err error) {
 	if m.ipv6index, err = readUint32(r, 26); err != nil {
 		return
 	}
-	m.ipv4bigidx = big.NewInt(int64(m.ipv4index))
-	m.ipv6bigidx = big.NewInt(int64(m.ipv6index))
 	m.ipv4colsize = uint32(m.colsize * 4)               // 4 bytes each column
 	m.ipv6colsize = uint32(16 + ((m.colsize - 1) << 2)) // 4 bytes each column, except IPFrom column which is 16 bytes
 
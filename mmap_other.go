@@ -0,0 +1,18 @@
+//go:build !unix
+
+package ip2location
+
+import "os"
+
+// mmapFile reads the whole file where memory mapping is not available.
+func mmapFile(f *os.File, size int64) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := f.ReadAt(data, 0); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func munmap(data []byte) error {
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package ip2location
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the file read-only.
+func mmapFile(f *os.File, size int64) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmap(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -1,27 +1,65 @@
 package ip2location
 
+import (
+	"context"
+	"errors"
+)
+
+// MultiDB queries several databases into the same Record.
+// Each database overwrites the fields it has, so the last database wins; use MergeDB to choose differently.
+// It is safe for concurrent use if all its databases are.
 type MultiDB []IP2LocationDB
 
-func (md MultiDB) Close() {
+// modeSupporter is implemented by databases that report their fields.
+type modeSupporter interface {
+	SupportedModes() QueryMode
+}
+
+// SupportedModes returns the union of the fields of its databases.
+// Databases that do not report their fields are not included.
+func (md MultiDB) SupportedModes() (mode QueryMode) {
+	for _, db := range md {
+		if db, ok := db.(modeSupporter); ok {
+			mode |= db.SupportedModes()
+		}
+	}
+	return
+}
+
+func (md MultiDB) Close() error {
+	var errs []error
 	for _, db := range md {
-		db.Close()
+		if err := db.Close(); err != nil {
+			errs = append(errs, err)
+		}
 	}
+	return errors.Join(errs...)
 }
 
 func (md MultiDB) Query(ip string, r *Record, mode QueryMode) error {
+	return md.QueryContext(context.Background(), ip, r, mode)
+}
+
+// QueryContext is like Query but stops when ctx is done.
+// With QueryStrict every requested field must be supported by at least one database.
+func (md MultiDB) QueryContext(ctx context.Context, ip string, r *Record, mode QueryMode) error {
+	mode, err := md.strict(ip, mode)
+	if err != nil {
+		return err
+	}
 	matches := 0
 	var lasterr error
 	for _, db := range md {
-		if err := db.Query(ip, r, mode); err != nil {
-			switch err {
-			case NotSupportedError, UnsupportedAddressTypeError, NoMatchError:
-				lasterr = err
-			default:
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := db.QueryContext(ctx, ip, r, mode); err != nil {
+			if !skippable(err) {
 				return err
 			}
+			lasterr = err
 		} else {
 			matches++
-
 		}
 	}
 	if matches == 0 {
@@ -29,3 +67,20 @@ func (md MultiDB) Query(ip string, r *Record, mode QueryMode) error {
 	}
 	return nil
 }
+
+// strict checks a QueryStrict mode against the union of the databases and returns the mode to query them with.
+func (md MultiDB) strict(ip string, mode QueryMode) (QueryMode, error) {
+	if mode&QueryStrict == 0 {
+		return mode, nil
+	}
+	supported := md.SupportedModes()
+	if mode&QueryAll&^supported != 0 {
+		return 0, &QueryError{IP: ip, Mode: mode, Supported: supported, Err: NotSupportedError}
+	}
+	return mode &^ QueryStrict, nil
+}
+
+// skippable reports whether a database failed only because it cannot answer the query.
+func skippable(err error) bool {
+	return errors.Is(err, NotSupportedError) || errors.Is(err, UnsupportedAddressTypeError) || errors.Is(err, NoMatchError)
+}
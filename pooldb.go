@@ -1,17 +1,25 @@
 package ip2location
 
 import (
+	"context"
+	"errors"
 	"sync"
 )
 
 // PoolDB allows for db pooling
+// Each query runs on a database taken from the pool, so factories may return databases that are not safe for concurrent use.
 type PoolDB struct {
+	once    sync.Once
 	pool    *sync.Pool
 	Factory func() (IP2LocationDB, error)
 }
 
 func (p *PoolDB) Query(ip string, r *Record, m QueryMode) error {
-	if p.pool == nil {
+	return p.QueryContext(context.Background(), ip, r, m)
+}
+
+func (p *PoolDB) QueryContext(ctx context.Context, ip string, r *Record, m QueryMode) error {
+	p.once.Do(func() {
 		p.pool = &sync.Pool{
 			New: func() interface{} {
 				if db, err := p.Factory(); err == nil {
@@ -21,13 +29,17 @@ func (p *PoolDB) Query(ip string, r *Record, m QueryMode) error {
 				}
 			},
 		}
-	}
+	})
 	db := p.pool.Get().(IP2LocationDB)
-	defer p.pool.Put(db)
-	return db.Query(ip, r, m)
+	err := db.QueryContext(ctx, ip, r, m)
+	// failed factories and closed databases are dropped so the factory runs again
+	if _, failed := db.(*errorDB); !failed && !errors.Is(err, DBClosedError) {
+		p.pool.Put(db)
+	}
+	return err
 }
-func (p *PoolDB) Close() {
-
+func (p *PoolDB) Close() error {
+	return nil
 }
 
 type errorDB struct {
@@ -37,4 +49,9 @@ type errorDB struct {
 func (e *errorDB) Query(string, *Record, QueryMode) error {
 	return e.Error
 }
-func (e *errorDB) Close() {}
+func (e *errorDB) QueryContext(context.Context, string, *Record, QueryMode) error {
+	return e.Error
+}
+func (e *errorDB) Close() error {
+	return nil
+}
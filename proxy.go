@@ -0,0 +1,349 @@
+package ip2location
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/netip"
+)
+
+// ProxyDBType is the product type of an IP2Proxy database.
+type ProxyDBType uint8
+
+const (
+	PX1 ProxyDBType = iota + 1
+	PX2
+	PX3
+	PX4
+	PX5
+	PX6
+	PX7
+	PX8
+	PX9
+	PX10
+	PX11
+	maxpx
+)
+
+type ProxyQueryMode uint32
+
+const (
+	ProxyQueryCountryCode ProxyQueryMode = 0x0001
+	ProxyQueryCountryName ProxyQueryMode = 0x0002
+	ProxyQueryRegion      ProxyQueryMode = 0x0004
+	ProxyQueryCity        ProxyQueryMode = 0x0008
+	ProxyQueryISP         ProxyQueryMode = 0x0010
+	ProxyQueryProxyType   ProxyQueryMode = 0x0020
+	ProxyQueryDomain      ProxyQueryMode = 0x0040
+	ProxyQueryUsageType   ProxyQueryMode = 0x0080
+	ProxyQueryASN         ProxyQueryMode = 0x0100
+	ProxyQueryAS          ProxyQueryMode = 0x0200
+	ProxyQueryLastSeen    ProxyQueryMode = 0x0400
+	ProxyQueryThreat      ProxyQueryMode = 0x0800
+	ProxyQueryProvider    ProxyQueryMode = 0x1000
+	ProxyQueryAll         ProxyQueryMode = ProxyQueryCountryCode | ProxyQueryCountryName | ProxyQueryRegion | ProxyQueryCity | ProxyQueryISP | ProxyQueryProxyType | ProxyQueryDomain | ProxyQueryUsageType | ProxyQueryASN | ProxyQueryAS | ProxyQueryLastSeen | ProxyQueryThreat | ProxyQueryProvider
+)
+
+const proxyQueryCountry = ProxyQueryCountryCode | ProxyQueryCountryName
+
+var (
+	pxColsGeo     = []ProxyQueryMode{ProxyQueryProxyType, proxyQueryCountry, ProxyQueryRegion, ProxyQueryCity}
+	pxColsNetwork = cols(pxColsGeo, ProxyQueryISP, ProxyQueryDomain, ProxyQueryUsageType, ProxyQueryASN, ProxyQueryAS)
+	pxColsThreat  = cols(pxColsNetwork, ProxyQueryLastSeen, ProxyQueryThreat)
+)
+
+// pxLayouts lists the columns following IPFrom in each proxy database type, in file order.
+var pxLayouts = [maxpx][]ProxyQueryMode{
+	PX1:  {proxyQueryCountry},
+	PX2:  {ProxyQueryProxyType, proxyQueryCountry},
+	PX3:  pxColsGeo,
+	PX4:  cols(pxColsGeo, ProxyQueryISP),
+	PX5:  cols(pxColsGeo, ProxyQueryISP, ProxyQueryDomain),
+	PX6:  cols(pxColsGeo, ProxyQueryISP, ProxyQueryDomain, ProxyQueryUsageType),
+	PX7:  pxColsNetwork,
+	PX8:  cols(pxColsNetwork, ProxyQueryLastSeen),
+	PX9:  pxColsThreat,
+	PX10: pxColsThreat,
+	PX11: cols(pxColsThreat, ProxyQueryProvider),
+}
+
+// proxyQueryFields lists every proxy field in the column order of IP2Proxy CSV files along with its column name.
+var proxyQueryFields = []struct {
+	mode ProxyQueryMode
+	name string
+}{
+	{ProxyQueryProxyType, "proxy_type"},
+	{ProxyQueryCountryCode, "country_code"},
+	{ProxyQueryCountryName, "country_name"},
+	{ProxyQueryRegion, "region_name"},
+	{ProxyQueryCity, "city_name"},
+	{ProxyQueryISP, "isp"},
+	{ProxyQueryDomain, "domain"},
+	{ProxyQueryUsageType, "usage_type"},
+	{ProxyQueryASN, "asn"},
+	{ProxyQueryAS, "as"},
+	{ProxyQueryLastSeen, "last_seen"},
+	{ProxyQueryThreat, "threat"},
+	{ProxyQueryProvider, "provider"},
+}
+
+// Fields splits the mode into single field modes in CSV column order.
+func (m ProxyQueryMode) Fields() []ProxyQueryMode {
+	fields := make([]ProxyQueryMode, 0, len(proxyQueryFields))
+	for _, f := range proxyQueryFields {
+		if m&f.mode != 0 {
+			fields = append(fields, f.mode)
+		}
+	}
+	return fields
+}
+
+// Name returns the column name of a single field mode.
+func (m ProxyQueryMode) Name() string {
+	for _, f := range proxyQueryFields {
+		if f.mode == m {
+			return f.name
+		}
+	}
+	return ""
+}
+
+// Modes returns the fields stored in proxy databases of type t.
+func (t ProxyDBType) Modes() ProxyQueryMode {
+	var mode ProxyQueryMode
+	if t < maxpx {
+		for _, m := range pxLayouts[t] {
+			mode |= m
+		}
+	}
+	return mode
+}
+
+// Columns returns the fields of each column following IPFrom in proxy databases of type t, in file order.
+// The country column holds both country code and name.
+func (t ProxyDBType) Columns() []ProxyQueryMode {
+	if t >= maxpx {
+		return nil
+	}
+	return cols(pxLayouts[t])
+}
+
+// ProxyRecord holds the fields of an IP2Proxy database.
+type ProxyRecord struct {
+	CountryCode string
+	CountryName string
+	Region      string
+	City        string
+	ISP         string
+	ProxyType   string
+	Domain      string
+	UsageType   string
+	ASN         string
+	AS          string
+	LastSeen    string
+	Threat      string
+	Provider    string
+}
+
+// field returns the field of a single field mode.
+func (x *ProxyRecord) field(m ProxyQueryMode) *string {
+	switch m {
+	case ProxyQueryCountryCode:
+		return &x.CountryCode
+	case ProxyQueryCountryName:
+		return &x.CountryName
+	case ProxyQueryRegion:
+		return &x.Region
+	case ProxyQueryCity:
+		return &x.City
+	case ProxyQueryISP:
+		return &x.ISP
+	case ProxyQueryProxyType:
+		return &x.ProxyType
+	case ProxyQueryDomain:
+		return &x.Domain
+	case ProxyQueryUsageType:
+		return &x.UsageType
+	case ProxyQueryASN:
+		return &x.ASN
+	case ProxyQueryAS:
+		return &x.AS
+	case ProxyQueryLastSeen:
+		return &x.LastSeen
+	case ProxyQueryThreat:
+		return &x.Threat
+	case ProxyQueryProvider:
+		return &x.Provider
+	}
+	return nil
+}
+
+// Value returns the value of a single field mode or an empty string.
+func (x *ProxyRecord) Value(m ProxyQueryMode) string {
+	if f := x.field(m); f != nil {
+		return *f
+	}
+	return ""
+}
+
+// Set sets a single field mode; other modes are ignored.
+func (x *ProxyRecord) Set(m ProxyQueryMode, s string) {
+	if f := x.field(m); f != nil {
+		*f = s
+	}
+}
+
+// IsProxy reports whether the record describes a proxy.
+// PX1 databases only list proxies so any known country counts.
+func (x *ProxyRecord) IsProxy() bool {
+	if x.ProxyType != "" {
+		return x.ProxyType != "-"
+	}
+	return x.CountryCode != "" && x.CountryCode != "-"
+}
+
+// IsDataCenter reports whether the proxy is a data center or search engine range.
+func (x *ProxyRecord) IsDataCenter() bool {
+	return x.ProxyType == "DCH" || x.ProxyType == "SES"
+}
+
+// IsResidential reports whether the proxy is a residential proxy.
+func (x *ProxyRecord) IsResidential() bool {
+	return x.ProxyType == "RES"
+}
+
+// ProxyDB reads IP2Proxy databases.
+// The file layout is the same as IP2Location databases with different columns.
+type ProxyDB struct {
+	r       io.ReaderAt
+	meta    DBMeta
+	columns []column[ProxyQueryMode]
+	mode    ProxyQueryMode
+}
+
+func OpenProxyDB(dbpath string) (*ProxyDB, error) {
+	data, err := ioutil.ReadFile(dbpath)
+	if err != nil {
+		return nil, err
+	}
+	return NewProxyDB(bytes.NewReader(data))
+}
+
+func NewProxyDB(r io.ReaderAt) (db *ProxyDB, err error) {
+	db = &ProxyDB{r: r}
+	if err = db.meta.Read(r); err != nil {
+		return
+	}
+	t := db.Type()
+	if t >= maxpx {
+		return nil, UnsupportedDatabaseError
+	}
+	if db.columns, db.mode = layoutColumns(pxLayouts[t]); db.mode == 0 {
+		return nil, UnsupportedDatabaseError
+	}
+	return db, nil
+}
+
+func (db *ProxyDB) Close() error {
+	return nil
+}
+
+func (db *ProxyDB) Type() ProxyDBType {
+	return ProxyDBType(db.meta.dbtype)
+}
+
+func (db *ProxyDB) Query(ipaddress string, x *ProxyRecord, mode ProxyQueryMode) error {
+	addr, err := netip.ParseAddr(ipaddress)
+	if err != nil {
+		return &QueryError{IP: ipaddress, Err: InvalidAddressError}
+	}
+	if err := db.query(addr, x, mode); err != nil {
+		return &QueryError{IP: ipaddress, Err: err}
+	}
+	return nil
+}
+
+func (db *ProxyDB) QueryAddr(addr netip.Addr, x *ProxyRecord, mode ProxyQueryMode) error {
+	if err := db.query(addr, x, mode); err != nil {
+		return &QueryError{IP: addr.String(), Err: err}
+	}
+	return nil
+}
+
+func (db *ProxyDB) query(addr netip.Addr, x *ProxyRecord, mode ProxyQueryMode) error {
+	ip, t := AddrNumber(addr)
+	if t == 0 {
+		return InvalidAddressError
+	}
+	if mode&db.mode == 0 {
+		return NotSupportedError
+	}
+	row, _, _, err := db.meta.search(context.Background(), db.r, ip, t)
+	if err != nil {
+		return err
+	}
+	return db.decode(row, x, mode)
+}
+
+// IsProxy reports whether ipaddress is a known proxy.
+// Addresses not found in the database are not proxies.
+func (db *ProxyDB) IsProxy(ipaddress string) (bool, error) {
+	x := ProxyRecord{}
+	err := db.Query(ipaddress, &x, (ProxyQueryProxyType|ProxyQueryCountryCode)&db.mode)
+	switch {
+	case err == nil:
+		return x.IsProxy(), nil
+	case errors.Is(err, NoMatchError), errors.Is(err, UnsupportedAddressTypeError):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// decode reads the columns selected by mode from a row into x.
+func (db *ProxyDB) decode(row uint32, x *ProxyRecord, mode ProxyQueryMode) (err error) {
+	var pos uint32
+	for _, c := range db.columns {
+		m := c.mode
+		if mode&m == 0 {
+			continue
+		}
+		if pos, err = readUint32(db.r, row+c.offset); err != nil {
+			return
+		}
+		switch m {
+		case ProxyQueryCountryCode:
+			x.CountryCode, err = readString(db.r, pos)
+		case ProxyQueryCountryName:
+			x.CountryName, err = readString(db.r, pos+3)
+		case ProxyQueryRegion:
+			x.Region, err = readString(db.r, pos)
+		case ProxyQueryCity:
+			x.City, err = readString(db.r, pos)
+		case ProxyQueryISP:
+			x.ISP, err = readString(db.r, pos)
+		case ProxyQueryProxyType:
+			x.ProxyType, err = readString(db.r, pos)
+		case ProxyQueryDomain:
+			x.Domain, err = readString(db.r, pos)
+		case ProxyQueryUsageType:
+			x.UsageType, err = readString(db.r, pos)
+		case ProxyQueryASN:
+			x.ASN, err = readString(db.r, pos)
+		case ProxyQueryAS:
+			x.AS, err = readString(db.r, pos)
+		case ProxyQueryLastSeen:
+			x.LastSeen, err = readString(db.r, pos)
+		case ProxyQueryThreat:
+			x.Threat, err = readString(db.r, pos)
+		case ProxyQueryProvider:
+			x.Provider, err = readString(db.r, pos)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return nil
+}
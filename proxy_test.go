@@ -0,0 +1,182 @@
+package ip2location_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	ip2loc "github.com/alxarch/ip2location-go"
+	"github.com/alxarch/ip2location-go/ip2locationtest"
+)
+
+func Test_ProxyDBTypes(t *testing.T) {
+	blocks := map[string]string{
+		"1.0.0.0/24":          "GR",
+		"1.0.255.0-1.2.0.255": "FR",
+		"255.255.255.0/24":    "US",
+		"2001:db8::/32":       "GR",
+		"ffff:ffff::/32":      "US",
+	}
+	queries := map[string]string{
+		"0.0.0.0":                                "",
+		"1.0.0.0":                                "GR",
+		"1.0.0.255":                              "GR",
+		"::ffff:1.0.0.1":                         "GR",
+		"1.0.1.0":                                "",
+		"1.1.128.0":                              "FR",
+		"1.2.1.0":                                "",
+		"255.255.255.255":                        "US",
+		"::1":                                    "",
+		"2001:db8::":                             "GR",
+		"2001:db8:ffff:ffff:ffff:ffff:ffff:ffff": "GR",
+		"2001:db9::":                             "",
+		"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff": "US",
+	}
+	for typ := ip2loc.PX1; typ <= ip2loc.PX11; typ++ {
+		for _, index := range []bool{true, false} {
+			spec := ip2locationtest.ProxySpec{Type: typ, NoIndex: !index}
+			for r, code := range blocks {
+				spec.Blocks = append(spec.Blocks, ip2locationtest.ProxyBlock{Range: r, Record: ip2locationtest.ProxyRecord(typ.Modes(), code)})
+			}
+			db := ip2locationtest.NewProxy(t, spec)
+			if db.Type() != typ {
+				t.Errorf("PX%d: invalid type %d", typ, db.Type())
+			}
+			for ip, code := range queries {
+				x := ip2loc.ProxyRecord{}
+				err := db.Query(ip, &x, ip2loc.ProxyQueryAll)
+				if code == "" {
+					var qe *ip2loc.QueryError
+					if !errors.As(err, &qe) || qe.IP != ip || !errors.Is(err, ip2loc.NoMatchError) {
+						t.Errorf("PX%d %s: expected no match, got %v", typ, ip, err)
+					}
+					continue
+				}
+				want := ip2locationtest.ProxyRecord(typ.Modes(), code)
+				if err != nil || x != want {
+					t.Errorf("PX%d %s: invalid record %v %v", typ, ip, x, err)
+					continue
+				}
+				for _, m := range ip2loc.ProxyQueryAll.Fields() {
+					x := ip2loc.ProxyRecord{}
+					err := db.Query(ip, &x, m)
+					if typ.Modes()&m == 0 {
+						if !errors.Is(err, ip2loc.NotSupportedError) {
+							t.Errorf("PX%d %s: expected %s not supported, got %v", typ, ip, m.Name(), err)
+						}
+						continue
+					}
+					if err != nil || x.Value(m) != want.Value(m) || x.Value(m) == "" {
+						t.Errorf("PX%d %s: invalid %s %q %v", typ, ip, m.Name(), x.Value(m), err)
+					}
+					x.Set(m, "")
+					if x != (ip2loc.ProxyRecord{}) {
+						t.Errorf("PX%d %s: %s query set other fields %v", typ, ip, m.Name(), x)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Test_ProxyLayouts checks the written files against the column positions of the IP2Proxy BIN format,
+// where IPFrom is column 1 and zero marks a missing column.
+func Test_ProxyLayouts(t *testing.T) {
+	positions := map[ip2loc.ProxyQueryMode][12]int{
+		ip2loc.ProxyQueryCountryCode: {0, 2, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3},
+		ip2loc.ProxyQueryRegion:      {0, 0, 0, 4, 4, 4, 4, 4, 4, 4, 4, 4},
+		ip2loc.ProxyQueryCity:        {0, 0, 0, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+		ip2loc.ProxyQueryISP:         {0, 0, 0, 0, 6, 6, 6, 6, 6, 6, 6, 6},
+		ip2loc.ProxyQueryProxyType:   {0, 0, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2},
+		ip2loc.ProxyQueryDomain:      {0, 0, 0, 0, 0, 7, 7, 7, 7, 7, 7, 7},
+		ip2loc.ProxyQueryUsageType:   {0, 0, 0, 0, 0, 0, 8, 8, 8, 8, 8, 8},
+		ip2loc.ProxyQueryASN:         {0, 0, 0, 0, 0, 0, 0, 9, 9, 9, 9, 9},
+		ip2loc.ProxyQueryAS:          {0, 0, 0, 0, 0, 0, 0, 10, 10, 10, 10, 10},
+		ip2loc.ProxyQueryLastSeen:    {0, 0, 0, 0, 0, 0, 0, 0, 11, 11, 11, 11},
+		ip2loc.ProxyQueryThreat:      {0, 0, 0, 0, 0, 0, 0, 0, 0, 12, 12, 12},
+		ip2loc.ProxyQueryProvider:    {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 13},
+	}
+	for typ := ip2loc.PX1; typ <= ip2loc.PX11; typ++ {
+		want := ip2locationtest.ProxyRecord(typ.Modes(), "GR")
+		spec := ip2locationtest.ProxySpec{Type: typ, NoIndex: true, Blocks: []ip2locationtest.ProxyBlock{{Range: "0.0.0.0-255.255.255.255", Record: want}}}
+		data, err := spec.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the block is the first row of the IPv4 table
+		row := binary.LittleEndian.Uint32(data[9:]) - 1
+		str := func(pos uint32) string {
+			return string(data[pos+1 : pos+1+uint32(data[pos])])
+		}
+		var mode ip2loc.ProxyQueryMode
+		for m, pos := range positions {
+			p := pos[typ]
+			if p == 0 {
+				continue
+			}
+			mode |= m
+			ptr := binary.LittleEndian.Uint32(data[row+uint32(p-1)*4:])
+			if got := str(ptr); got != want.Value(m) {
+				t.Errorf("PX%d: invalid %s %q in column %d", typ, m.Name(), got, p)
+			}
+			if m == ip2loc.ProxyQueryCountryCode && str(ptr+3) != want.CountryName {
+				t.Errorf("PX%d: invalid country name %q in column %d", typ, str(ptr+3), p)
+			}
+		}
+		if mode|ip2loc.ProxyQueryCountryName != typ.Modes() {
+			t.Errorf("PX%d: invalid fields %x", typ, typ.Modes())
+		}
+	}
+}
+
+func Test_ProxyDBIsProxy(t *testing.T) {
+	for _, tc := range []struct {
+		typ   ip2loc.ProxyDBType
+		proxy ip2loc.ProxyRecord
+		none  ip2loc.ProxyRecord
+	}{
+		{ip2loc.PX1, ip2loc.ProxyRecord{CountryCode: "GR", CountryName: "Greece"}, ip2loc.ProxyRecord{CountryCode: "-", CountryName: "-"}},
+		{ip2loc.PX2, ip2loc.ProxyRecord{ProxyType: "VPN", CountryCode: "GR"}, ip2loc.ProxyRecord{ProxyType: "-", CountryCode: "-"}},
+		{ip2loc.PX11, ip2loc.ProxyRecord{ProxyType: "DCH", CountryCode: "US", Provider: "Provider"}, ip2loc.ProxyRecord{ProxyType: "-", CountryCode: "-", Provider: "-"}},
+	} {
+		db := ip2locationtest.NewProxy(t, ip2locationtest.ProxySpec{
+			Type: tc.typ,
+			Blocks: []ip2locationtest.ProxyBlock{
+				{Range: "1.0.0.0/24", Record: tc.proxy},
+				{Range: "1.0.1.0/24", Record: tc.none},
+				{Range: "2001:db8::/32", Record: tc.proxy},
+				{Range: "2001:db9::/32", Record: tc.none},
+			},
+		})
+		for ip, want := range map[string]bool{
+			"1.0.0.1":        true,
+			"::ffff:1.0.0.1": true,
+			"1.0.1.1":        false,
+			"1.0.2.1":        false,
+			"2001:db8::1":    true,
+			"2001:db9::1":    false,
+			"2001:dba::1":    false,
+		} {
+			if ok, err := db.IsProxy(ip); err != nil || ok != want {
+				t.Errorf("PX%d %s: IsProxy %t %v", tc.typ, ip, ok, err)
+			}
+		}
+		var qe *ip2loc.QueryError
+		if _, err := db.IsProxy("not an address"); !errors.As(err, &qe) || !errors.Is(err, ip2loc.InvalidAddressError) {
+			t.Errorf("PX%d: expected invalid address, got %v", tc.typ, err)
+		}
+	}
+
+	// databases without an IPv6 table have no IPv6 proxies
+	db := ip2locationtest.NewProxy(t, ip2locationtest.ProxySpec{
+		Type:   ip2loc.PX2,
+		Blocks: []ip2locationtest.ProxyBlock{{Range: "1.0.0.0/24", Record: ip2loc.ProxyRecord{ProxyType: "PUB", CountryCode: "GR"}}},
+	})
+	if ok, err := db.IsProxy("2001:db8::1"); ok || err != nil {
+		t.Errorf("IsProxy %t %v", ok, err)
+	}
+	x := ip2loc.ProxyRecord{ProxyType: "DCH"}
+	if !x.IsDataCenter() || x.IsResidential() {
+		t.Errorf("Invalid proxy type checks %v", x)
+	}
+}
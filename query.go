@@ -1,5 +1,10 @@
 package ip2location
 
+import (
+	"fmt"
+	"strings"
+)
+
 type QueryMode uint32
 
 const (
@@ -23,5 +28,115 @@ const (
 	QueryMobileBrand        QueryMode = 0x20000
 	QueryElevation          QueryMode = 0x40000
 	QueryUsageType          QueryMode = 0x80000
-	QueryAll                QueryMode = QueryCountryCode | QueryCountryName | QueryRegion | QueryCity | QueryISP | QueryLatitude | QueryLongitude | QueryDomain | QueryZipCode | QueryTimeZone | QueryNetSpeed | QueryIDDCode | QueryAreaCode | QueryWeatherStationCode | QueryWeatherStationName | QueryMCC | QueryMNC | QueryMobileBrand | QueryElevation | QueryUsageType
+	QueryAddressType        QueryMode = 0x100000
+	QueryCategory           QueryMode = 0x200000
+	QueryDistrict           QueryMode = 0x400000
+	QueryASN                QueryMode = 0x800000
+	QueryAS                 QueryMode = 0x1000000
+	QueryAll                QueryMode = QueryCountryCode | QueryCountryName | QueryRegion | QueryCity | QueryISP | QueryLatitude | QueryLongitude | QueryDomain | QueryZipCode | QueryTimeZone | QueryNetSpeed | QueryIDDCode | QueryAreaCode | QueryWeatherStationCode | QueryWeatherStationName | QueryMCC | QueryMNC | QueryMobileBrand | QueryElevation | QueryUsageType | QueryAddressType | QueryCategory | QueryDistrict | QueryASN | QueryAS
+
+	// QueryStrict makes queries fail with NotSupportedError unless the database has every requested field.
+	QueryStrict QueryMode = 0x80000000
 )
+
+// queryFields lists every field in the column order of IP2Location CSV files along with its column name.
+var queryFields = []struct {
+	mode QueryMode
+	name string
+}{
+	{QueryCountryCode, "country_code"},
+	{QueryCountryName, "country_name"},
+	{QueryRegion, "region_name"},
+	{QueryCity, "city_name"},
+	{QueryLatitude, "latitude"},
+	{QueryLongitude, "longitude"},
+	{QueryZipCode, "zip_code"},
+	{QueryTimeZone, "time_zone"},
+	{QueryISP, "isp"},
+	{QueryDomain, "domain"},
+	{QueryNetSpeed, "net_speed"},
+	{QueryIDDCode, "idd_code"},
+	{QueryAreaCode, "area_code"},
+	{QueryWeatherStationCode, "weather_station_code"},
+	{QueryWeatherStationName, "weather_station_name"},
+	{QueryMCC, "mcc"},
+	{QueryMNC, "mnc"},
+	{QueryMobileBrand, "mobile_brand"},
+	{QueryElevation, "elevation"},
+	{QueryUsageType, "usage_type"},
+	{QueryAddressType, "address_type"},
+	{QueryCategory, "category"},
+	{QueryDistrict, "district"},
+	{QueryASN, "asn"},
+	{QueryAS, "as"},
+}
+
+// Fields splits the mode into single field modes in CSV column order.
+func (m QueryMode) Fields() []QueryMode {
+	fields := make([]QueryMode, 0, len(queryFields))
+	for _, f := range queryFields {
+		if m&f.mode != 0 {
+			fields = append(fields, f.mode)
+		}
+	}
+	return fields
+}
+
+// Name returns the column name of a single field mode.
+func (m QueryMode) Name() string {
+	for _, f := range queryFields {
+		if f.mode == m {
+			return f.name
+		}
+	}
+	return ""
+}
+
+// String returns the comma separated column names of the fields in the mode, followed by "strict" for QueryStrict.
+func (m QueryMode) String() string {
+	names := make([]string, 0, len(queryFields)+1)
+	for _, f := range m.Fields() {
+		names = append(names, f.Name())
+	}
+	if m&QueryStrict != 0 {
+		names = append(names, "strict")
+	}
+	return strings.Join(names, ",")
+}
+
+// queryAliases are short names accepted by ParseQueryMode.
+var queryAliases = map[string]QueryMode{
+	"all":     QueryAll,
+	"country": QueryCountryCode | QueryCountryName,
+	"region":  QueryRegion,
+	"city":    QueryCity,
+	"strict":  QueryStrict,
+}
+
+// ParseQueryMode parses a comma separated list of column names.
+// Underscores are optional and "all", "country", "region" and "city" are accepted as short names.
+// The name "strict" adds QueryStrict.
+func ParseQueryMode(s string) (mode QueryMode, err error) {
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		m, ok := queryAliases[name]
+		if !ok {
+			m = queryModeByName(name)
+		}
+		if m == 0 {
+			return 0, fmt.Errorf("unknown field %q", name)
+		}
+		mode |= m
+	}
+	return mode, nil
+}
+
+func queryModeByName(name string) QueryMode {
+	name = strings.ReplaceAll(name, "_", "")
+	for _, f := range queryFields {
+		if strings.ReplaceAll(f.name, "_", "") == name {
+			return f.mode
+		}
+	}
+	return 0
+}
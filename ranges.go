@@ -0,0 +1,172 @@
+package ip2location
+
+import (
+	"iter"
+	"net/netip"
+)
+
+// Range is a block of addresses [From, To) as stored in a database table.
+// The last block of a table also covers the maximum address.
+type Range struct {
+	Type     IPType
+	From, To Uint128
+}
+
+func (t IPType) bits() int {
+	switch t {
+	case IPv4:
+		return 32
+	case IPv6:
+		return 128
+	}
+	return 0
+}
+
+func (t IPType) max() Uint128 {
+	switch t {
+	case IPv4:
+		return max_ipv4_range
+	case IPv6:
+		return max_ipv6_range
+	}
+	return Uint128{}
+}
+
+// last returns the IP number of the last address in the range.
+func (r Range) last() Uint128 {
+	if r.To == r.Type.max() {
+		return r.To
+	}
+	return r.To.Sub1()
+}
+
+// Start returns the first address in the range.
+func (r Range) Start() netip.Addr {
+	return r.From.Addr(r.Type)
+}
+
+// End returns the last address in the range.
+func (r Range) End() netip.Addr {
+	return r.last().Addr(r.Type)
+}
+
+// Contains reports whether addr is inside the range.
+func (r Range) Contains(addr netip.Addr) bool {
+	n, t := AddrNumber(addr)
+	return t == r.Type && !n.Less(r.From) && !r.last().Less(n)
+}
+
+// Prefixes returns the smallest list of prefixes covering the range.
+func (r Range) Prefixes() (prefixes []netip.Prefix) {
+	bits := r.Type.bits()
+	start, end := r.From, r.last()
+	for bits > 0 && !end.Less(start) {
+		n := start.TrailingZeros()
+		if n > bits {
+			n = bits
+		}
+		var last Uint128
+		for ; ; n-- {
+			last = start.Add(Uint128{Lo: 1}.Lsh(uint(n))).Sub1()
+			if !end.Less(last) && !last.Less(start) {
+				break
+			}
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(start.Addr(r.Type), bits-n))
+		if last == end {
+			break
+		}
+		start = last.Add1()
+	}
+	return
+}
+
+// RangeCursor iterates over the blocks of a database table in address order.
+//
+//	c := db.Ranges(IPv4, QueryCountryCode)
+//	for c.Next() {
+//		fmt.Println(c.Range().Start(), c.Record().CountryCode)
+//	}
+//	if err := c.Err(); err != nil {
+//		...
+//	}
+type RangeCursor struct {
+	db   *DB
+	t    IPType
+	mode QueryMode
+	i, n uint32
+	rng  Range
+	rec  Record
+	err  error
+}
+
+// Ranges returns a cursor over every block of table t, without the unused rows of partial databases.
+// Records are decoded with the fields selected by mode; a zero mode only reads ranges.
+func (db *DB) Ranges(t IPType, mode QueryMode) *RangeCursor {
+	c := &RangeCursor{db: db, t: t, mode: mode}
+	switch t {
+	case IPv4:
+		c.n = db.meta.ipv4count
+	case IPv6:
+		c.n = db.meta.ipv6count
+	default:
+		c.err = UnsupportedAddressTypeError
+	}
+	if mode != 0 && mode&db.mode == 0 {
+		c.err = NotSupportedError
+	}
+	return c
+}
+
+// Next advances the cursor to the next block, skipping unused rows.
+// It returns false at the end of the table or on error.
+func (c *RangeCursor) Next() bool {
+	for c.err == nil && c.i < c.n {
+		row, from, to, unused, err := c.db.meta.row(c.db.r, c.i, c.t)
+		if err != nil {
+			c.err = err
+			return false
+		}
+		c.i++
+		if unused {
+			continue
+		}
+		c.rng = Range{Type: c.t, From: from, To: to}
+		c.rec = Record{}
+		if c.mode != 0 {
+			if c.err = c.db.decode(row, &c.rec, c.mode); c.err != nil {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Range returns the current block.
+func (c *RangeCursor) Range() Range {
+	return c.rng
+}
+
+// Record returns the record of the current block.
+// It is overwritten by the next call to Next.
+func (c *RangeCursor) Record() *Record {
+	return &c.rec
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (c *RangeCursor) Err() error {
+	return c.err
+}
+
+// All returns an iterator over the remaining blocks.
+// Check Err after the loop completes.
+func (c *RangeCursor) All() iter.Seq2[Range, *Record] {
+	return func(yield func(Range, *Record) bool) {
+		for c.Next() {
+			if !yield(c.rng, &c.rec) {
+				return
+			}
+		}
+	}
+}
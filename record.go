@@ -2,6 +2,11 @@ package ip2location
 
 import "fmt"
 
+// Record holds the fields of a query result.
+//
+// Mode has a bit set for every field populated by queries into the record
+// and selects the fields written by the marshaling methods.
+// Queries add to Mode, so reset a Record before reusing it for an unrelated query.
 type Record struct {
 	CountryCode        string
 	CountryName        string
@@ -23,53 +28,161 @@ type Record struct {
 	MobileBrand        string
 	Elevation          float64
 	UsageType          string
+	AddressType        string
+	Category           string
+	District           string
+	ASN                string
+	AS                 string
+	Mode               QueryMode
 }
 
-// func (x Record) String() string {
-// 	return fmt.Sprintf("%v", []string{
-// 		"CountryCode", x.CountryCode,
-// 		"CountryName", x.CountryName,
-// 		"Region", x.Region,
-// 		"City", x.City,
-// 		"ISP", x.ISP,
-// 		"Latitude", fmt.Sprint(x.Latitude),
-// 		"Longitude", fmt.Sprint(x.Longitude),
-// 		"Domain", x.Domain,
-// 		"ZipCode", x.ZipCode,
-// 		"Timezone", x.Timezone,
-// 		"NetSpeed", x.NetSpeed,
-// 		"IDDCode", x.IDDCode,
-// 		"Areacode", x.Areacode,
-// 		"WeatherStationCode", x.WeatherStationCode,
-// 		"WeatherStationName", x.WeatherStationName,
-// 		"MCC", x.MCC,
-// 		"MNC", x.MNC,
-// 		"MobileBrand", x.MobileBrand,
-// 		"Elevation", fmt.Sprint(x.Elevation),
-// 		"UsageType", x.UsageType,
-// 	})
-// }
+// String formats the populated fields like MarshalText.
+func (x Record) String() string {
+	text, _ := x.MarshalText()
+	return string(text)
+}
 
-// for debugging purposes
+// Print prints the populated fields one per line, for debugging purposes.
 func (x Record) Print() {
-	fmt.Printf("country_short: %s\n", x.CountryCode)
-	fmt.Printf("country_long: %s\n", x.CountryName)
-	fmt.Printf("QueryRegion: %s\n", x.Region)
-	fmt.Printf("QueryCity: %s\n", x.City)
-	fmt.Printf("QueryISP: %s\n", x.ISP)
-	fmt.Printf("QueryLatitude: %f\n", x.Latitude)
-	fmt.Printf("QueryLongitude: %f\n", x.Longitude)
-	fmt.Printf("QueryDomain: %s\n", x.Domain)
-	fmt.Printf("QueryZipCodeQueryTimeZone %s\n", x.ZipCode)
-	fmt.Printf("timezone: %s\n", x.Timezone)
-	fmt.Printf("QueryNetSpeed: %s\n", x.NetSpeed)
-	fmt.Printf("QueryIDDCode: %s\n", x.IDDCode)
-	fmt.Printf("QueryAreaCode: %s\n", x.Areacode)
-	fmt.Printf("QueryWeatherStationCode: %s\n", x.WeatherStationCode)
-	fmt.Printf("QueryWeatherStationName: %s\n", x.WeatherStationName)
-	fmt.Printf("QueryMCC: %s\ncheckip", x.MCC)
-	fmt.Printf("QueryMNC: %s\n", x.MNC)
-	fmt.Printf("QueryMobileBrand: %s\n", x.MobileBrand)
-	fmt.Printf("QueryElevation: %f\n", x.Elevation)
-	fmt.Printf("QueryUsageType: %s\n", x.UsageType)
+	for _, m := range x.Mode.Fields() {
+		fmt.Printf("%s: %s\n", m.Name(), x.format(m))
+	}
+}
+
+// Value returns the value of a single field mode.
+// Latitude and longitude are float32, elevation is float64 and all other fields are strings.
+func (x *Record) Value(m QueryMode) interface{} {
+	switch m {
+	case QueryCountryCode:
+		return x.CountryCode
+	case QueryCountryName:
+		return x.CountryName
+	case QueryRegion:
+		return x.Region
+	case QueryCity:
+		return x.City
+	case QueryISP:
+		return x.ISP
+	case QueryLatitude:
+		return x.Latitude
+	case QueryLongitude:
+		return x.Longitude
+	case QueryDomain:
+		return x.Domain
+	case QueryZipCode:
+		return x.ZipCode
+	case QueryTimeZone:
+		return x.Timezone
+	case QueryNetSpeed:
+		return x.NetSpeed
+	case QueryIDDCode:
+		return x.IDDCode
+	case QueryAreaCode:
+		return x.Areacode
+	case QueryWeatherStationCode:
+		return x.WeatherStationCode
+	case QueryWeatherStationName:
+		return x.WeatherStationName
+	case QueryMCC:
+		return x.MCC
+	case QueryMNC:
+		return x.MNC
+	case QueryMobileBrand:
+		return x.MobileBrand
+	case QueryElevation:
+		return x.Elevation
+	case QueryUsageType:
+		return x.UsageType
+	case QueryAddressType:
+		return x.AddressType
+	case QueryCategory:
+		return x.Category
+	case QueryDistrict:
+		return x.District
+	case QueryASN:
+		return x.ASN
+	case QueryAS:
+		return x.AS
+	}
+	return nil
+}
+
+// copyFrom copies the fields selected by mode from src.
+func (x *Record) copyFrom(src *Record, mode QueryMode) {
+	x.Mode |= src.Mode & mode
+	if mode&QueryCountryCode != 0 {
+		x.CountryCode = src.CountryCode
+	}
+	if mode&QueryCountryName != 0 {
+		x.CountryName = src.CountryName
+	}
+	if mode&QueryRegion != 0 {
+		x.Region = src.Region
+	}
+	if mode&QueryCity != 0 {
+		x.City = src.City
+	}
+	if mode&QueryISP != 0 {
+		x.ISP = src.ISP
+	}
+	if mode&QueryLatitude != 0 {
+		x.Latitude = src.Latitude
+	}
+	if mode&QueryLongitude != 0 {
+		x.Longitude = src.Longitude
+	}
+	if mode&QueryDomain != 0 {
+		x.Domain = src.Domain
+	}
+	if mode&QueryZipCode != 0 {
+		x.ZipCode = src.ZipCode
+	}
+	if mode&QueryTimeZone != 0 {
+		x.Timezone = src.Timezone
+	}
+	if mode&QueryNetSpeed != 0 {
+		x.NetSpeed = src.NetSpeed
+	}
+	if mode&QueryIDDCode != 0 {
+		x.IDDCode = src.IDDCode
+	}
+	if mode&QueryAreaCode != 0 {
+		x.Areacode = src.Areacode
+	}
+	if mode&QueryWeatherStationCode != 0 {
+		x.WeatherStationCode = src.WeatherStationCode
+	}
+	if mode&QueryWeatherStationName != 0 {
+		x.WeatherStationName = src.WeatherStationName
+	}
+	if mode&QueryMCC != 0 {
+		x.MCC = src.MCC
+	}
+	if mode&QueryMNC != 0 {
+		x.MNC = src.MNC
+	}
+	if mode&QueryMobileBrand != 0 {
+		x.MobileBrand = src.MobileBrand
+	}
+	if mode&QueryElevation != 0 {
+		x.Elevation = src.Elevation
+	}
+	if mode&QueryUsageType != 0 {
+		x.UsageType = src.UsageType
+	}
+	if mode&QueryAddressType != 0 {
+		x.AddressType = src.AddressType
+	}
+	if mode&QueryCategory != 0 {
+		x.Category = src.Category
+	}
+	if mode&QueryDistrict != 0 {
+		x.District = src.District
+	}
+	if mode&QueryASN != 0 {
+		x.ASN = src.ASN
+	}
+	if mode&QueryAS != 0 {
+		x.AS = src.AS
+	}
 }
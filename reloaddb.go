@@ -0,0 +1,243 @@
+package ip2location
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReloadableDB is a database file that can be replaced while in use.
+// Queries in flight when a new file is loaded complete on the old file,
+// which is closed once they have drained.
+type ReloadableDB struct {
+	// OnReload, if set, is called after every reload attempt made by Watch.
+	OnReload func(meta DBMeta, err error)
+
+	mmap    bool
+	current atomic.Pointer[FileDB]
+	gen     atomic.Uint64
+
+	mu     sync.Mutex // serializes reloads
+	path   string
+	stat   os.FileInfo
+	done   chan struct{}
+	closed bool
+}
+
+func NewReloadableDB(path string, mmap bool) (*ReloadableDB, error) {
+	rdb := &ReloadableDB{mmap: mmap}
+	if err := rdb.Reload(path); err != nil {
+		return nil, err
+	}
+	return rdb, nil
+}
+
+// Reload opens the database at path and swaps it in place of the current one.
+// The current database stays in use if the new file is invalid.
+// It fails with DBClosedError after Close.
+func (rdb *ReloadableDB) Reload(path string) error {
+	rdb.mu.Lock()
+	defer rdb.mu.Unlock()
+	return rdb.reload(path)
+}
+
+func (rdb *ReloadableDB) reload(path string) error {
+	if rdb.closed {
+		return DBClosedError
+	}
+	s, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if s.IsDir() {
+		return MissingFileError
+	}
+	fdb, err := openFileDB(path, s.Size(), rdb.mmap)
+	if err != nil {
+		return err
+	}
+	if err := validate(fdb.db); err != nil {
+		fdb.Close()
+		return err
+	}
+	rdb.path, rdb.stat = path, s
+	old := rdb.current.Swap(fdb)
+	// counted after the swap so that caches never keep records of the old file under the new generation
+	rdb.gen.Add(1)
+	if old != nil {
+		// waits for in-flight queries
+		old.Close()
+	}
+	return nil
+}
+
+// Generation counts the files loaded so far and changes after every successful reload.
+func (rdb *ReloadableDB) Generation() uint64 {
+	return rdb.gen.Load()
+}
+
+// validate checks that the tables described by the header can be read.
+func validate(db *DB) error {
+	for _, t := range []IPType{IPv4, IPv6} {
+		if !db.meta.Has(t) {
+			continue
+		}
+		if _, _, _, _, err := db.meta.row(db.r, 0, t); err != nil {
+			return MissingFileError
+		}
+		_, count, _, _ := db.meta.Indexes(t)
+		if _, _, _, _, err := db.meta.row(db.r, count-1, t); err != nil {
+			return MissingFileError
+		}
+	}
+	return nil
+}
+
+// Watch polls the current file every interval and reloads it when its size or modification time change.
+// Polling stops when the database is closed and does not start after Close.
+func (rdb *ReloadableDB) Watch(interval time.Duration) {
+	rdb.mu.Lock()
+	defer rdb.mu.Unlock()
+	if rdb.done != nil || rdb.closed {
+		return
+	}
+	done := make(chan struct{})
+	rdb.done = done
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				rdb.poll(done)
+			}
+		}
+	}()
+}
+
+func (rdb *ReloadableDB) poll(done chan struct{}) {
+	rdb.mu.Lock()
+	if rdb.done != done {
+		// closed while waiting for the lock
+		rdb.mu.Unlock()
+		return
+	}
+	s, err := os.Stat(rdb.path)
+	if err == nil && s.Size() == rdb.stat.Size() && s.ModTime().Equal(rdb.stat.ModTime()) {
+		rdb.mu.Unlock()
+		return
+	}
+	if err == nil {
+		err = rdb.reload(rdb.path)
+	}
+	rdb.mu.Unlock()
+	if rdb.OnReload != nil {
+		rdb.OnReload(rdb.Meta(), err)
+	}
+}
+
+// Query runs on the active database.
+// Queries that race with a reload and find their database closed are retried on the new one.
+func (rdb *ReloadableDB) Query(ip string, r *Record, mode QueryMode) error {
+	for {
+		fdb := rdb.current.Load()
+		if fdb == nil {
+			return &QueryError{IP: ip, Mode: mode, Err: DBClosedError}
+		}
+		if err := fdb.Query(ip, r, mode); !errors.Is(err, DBClosedError) {
+			return err
+		}
+	}
+}
+
+func (rdb *ReloadableDB) QueryContext(ctx context.Context, ip string, r *Record, mode QueryMode) error {
+	for {
+		fdb := rdb.current.Load()
+		if fdb == nil {
+			return &QueryError{IP: ip, Mode: mode, Err: DBClosedError}
+		}
+		if err := fdb.QueryContext(ctx, ip, r, mode); !errors.Is(err, DBClosedError) {
+			return err
+		}
+	}
+}
+
+func (rdb *ReloadableDB) QueryAddr(ip netip.Addr, r *Record, mode QueryMode) error {
+	for {
+		fdb := rdb.current.Load()
+		if fdb == nil {
+			return &QueryError{IP: ip.String(), Mode: mode, Err: DBClosedError}
+		}
+		if err := fdb.QueryAddr(ip, r, mode); !errors.Is(err, DBClosedError) {
+			return err
+		}
+	}
+}
+
+func (rdb *ReloadableDB) QueryRange(ip string, r *Record, mode QueryMode) (Range, error) {
+	for {
+		fdb := rdb.current.Load()
+		if fdb == nil {
+			return Range{}, &QueryError{IP: ip, Mode: mode, Err: DBClosedError}
+		}
+		if rng, err := fdb.QueryRange(ip, r, mode); !errors.Is(err, DBClosedError) {
+			return rng, err
+		}
+	}
+}
+
+func (rdb *ReloadableDB) QueryRangeContext(ctx context.Context, ip string, r *Record, mode QueryMode) (Range, error) {
+	for {
+		fdb := rdb.current.Load()
+		if fdb == nil {
+			return Range{}, &QueryError{IP: ip, Mode: mode, Err: DBClosedError}
+		}
+		if rng, err := fdb.QueryRangeContext(ctx, ip, r, mode); !errors.Is(err, DBClosedError) {
+			return rng, err
+		}
+	}
+}
+
+// Meta returns the header of the active database.
+func (rdb *ReloadableDB) Meta() DBMeta {
+	if fdb := rdb.current.Load(); fdb != nil {
+		return fdb.Meta()
+	}
+	return DBMeta{}
+}
+
+// SupportedModes returns the fields of the active database.
+func (rdb *ReloadableDB) SupportedModes() QueryMode {
+	if fdb := rdb.current.Load(); fdb != nil {
+		return fdb.SupportedModes()
+	}
+	return 0
+}
+
+// Date returns the release date of the active database.
+func (rdb *ReloadableDB) Date() time.Time {
+	m := rdb.Meta()
+	return m.Date()
+}
+
+// Close stops watching and closes the active database once in-flight queries complete.
+// The database cannot be reloaded afterwards.
+func (rdb *ReloadableDB) Close() error {
+	rdb.mu.Lock()
+	defer rdb.mu.Unlock()
+	rdb.closed = true
+	if rdb.done != nil {
+		close(rdb.done)
+		rdb.done = nil
+	}
+	if old := rdb.current.Swap(nil); old != nil {
+		return old.Close()
+	}
+	return nil
+}
@@ -1,19 +1,36 @@
 package ip2location
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"sync"
+)
 
 type request struct {
-	Mode   QueryMode
-	IP     string
-	Record *Record
+	ctx   context.Context
+	Mode  QueryMode
+	IP    string
+	reply chan response
 }
 
+type response struct {
+	Record Record
+	Err    error
+}
+
+// SafeDB serializes queries to a database that is not safe for concurrent use.
+// The databases of this package are safe for concurrent use and do not need it.
+//
+// Every query gets its own reply and the caller's Record is only written by the calling goroutine,
+// so queries abandoned through their context never race with the worker.
 type SafeDB struct {
 	db IP2LocationDB
 
-	done      chan struct{}
-	requests  chan request
-	responses chan error
+	done     chan struct{}
+	stopped  chan struct{}
+	requests chan request
+	once     sync.Once
+	err      error
 }
 
 func NewSafeDB(db IP2LocationDB) *SafeDB {
@@ -21,21 +38,23 @@ func NewSafeDB(db IP2LocationDB) *SafeDB {
 		return nil
 	}
 	sd := &SafeDB{
-		db:        db,
-		requests:  make(chan request, 1000),
-		responses: make(chan error, 1000),
-		done:      make(chan struct{}),
+		db:       db,
+		requests: make(chan request),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
 	}
 	go func() {
-		defer close(sd.requests)
-		defer close(sd.responses)
-		defer sd.Close()
+		defer close(sd.stopped)
 		for {
 			select {
 			case <-sd.done:
 				return
 			case req := <-sd.requests:
-				sd.responses <- sd.db.Query(req.IP, req.Record, req.Mode)
+				res := response{}
+				if res.Err = req.ctx.Err(); res.Err == nil {
+					res.Err = sd.db.QueryContext(req.ctx, req.IP, &res.Record, req.Mode)
+				}
+				req.reply <- res
 			}
 		}
 	}()
@@ -43,21 +62,55 @@ func NewSafeDB(db IP2LocationDB) *SafeDB {
 
 }
 
-func (d *SafeDB) Close() {
-	if nil != d.done {
-		close(d.done)
+// Close stops the worker after the running query and closes the database.
+// Pending and later queries return DBClosedError.
+func (d *SafeDB) Close() error {
+	if nil == d.done {
+		return nil
 	}
-	if nil != d.db {
-		d.db.Close()
+	d.once.Do(func() {
+		close(d.done)
+		<-d.stopped
+		d.err = d.db.Close()
+	})
+	return d.err
+}
+
+// SupportedModes returns the fields of the underlying database, if it reports them.
+func (d *SafeDB) SupportedModes() QueryMode {
+	if db, ok := d.db.(modeSupporter); ok {
+		return db.SupportedModes()
 	}
+	return 0
 }
 
 var NotRunningError = errors.New("DB service not running")
 
 func (d *SafeDB) Query(ip string, r *Record, q QueryMode) error {
+	return d.QueryContext(context.Background(), ip, r, q)
+}
+
+// QueryContext is like Query but gives up waiting when ctx is done.
+func (d *SafeDB) QueryContext(ctx context.Context, ip string, r *Record, q QueryMode) error {
 	if nil == d.requests {
 		return NotRunningError
 	}
-	d.requests <- request{q, ip, r}
-	return <-d.responses
+	// buffered so the worker never blocks on an abandoned query
+	reply := make(chan response, 1)
+	select {
+	case d.requests <- request{ctx, q, ip, reply}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-d.done:
+		return DBClosedError
+	}
+	select {
+	case res := <-reply:
+		if res.Err == nil {
+			r.copyFrom(&res.Record, q)
+		}
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,113 @@
+package ip2location_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	ip2loc "github.com/alxarch/ip2location-go"
+)
+
+var stressIPs = []string{"8.8.8.8", "1.1.1.1", "127.0.0.1", "2001:4860:4860::8888", "::1", "invalid"}
+
+// stress queries db from many goroutines and compares results with sequential queries to ref.
+func stress(t *testing.T, db ip2loc.IP2LocationDB, ref ip2loc.IP2LocationDB) {
+	type result struct {
+		r   ip2loc.Record
+		err error
+	}
+	expect := make(map[string]result)
+	for _, ip := range stressIPs {
+		res := result{}
+		res.err = cause(ref.Query(ip, &res.r, ip2loc.QueryAll))
+		expect[ip] = res
+	}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				ip := stressIPs[j%len(stressIPs)]
+				res := result{}
+				res.err = cause(db.Query(ip, &res.r, ip2loc.QueryAll))
+				if res != expect[ip] {
+					t.Errorf("Invalid result for %s: %v", ip, res.err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// cause returns the cause of a QueryError.
+func cause(err error) error {
+	var qe *ip2loc.QueryError
+	if errors.As(err, &qe) {
+		return qe.Err
+	}
+	return err
+}
+
+func Test_ConcurrentDB(t *testing.T) {
+	db, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	fdb, err := ip2loc.NewFileDB(binfile, true)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	defer fdb.Close()
+	stress(t, db, db)
+	stress(t, fdb, db)
+	stress(t, ip2loc.MultiDB{fdb, db}, db)
+	stress(t, ip2loc.NewCachedDB(db, 2), db)
+	stress(t, &ip2loc.PoolDB{Factory: func() (ip2loc.IP2LocationDB, error) {
+		return ip2loc.NewDB(dbfile)
+	}}, db)
+}
+
+func Test_SafeDB(t *testing.T) {
+	db, err := ip2loc.NewDB(dbfile)
+	if err != nil {
+		t.Fatalf("Failed to init db %s", err)
+	}
+	sdb := ip2loc.NewSafeDB(db)
+	stress(t, sdb, db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sdb.QueryContext(ctx, "8.8.8.8", &ip2loc.Record{}, ip2loc.QueryAll); !errors.Is(err, context.Canceled) {
+		t.Errorf("Query with canceled context %v", err)
+	}
+
+	// close while queries are running
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				err := sdb.Query("8.8.8.8", &ip2loc.Record{}, ip2loc.QueryAll)
+				if errors.Is(err, ip2loc.DBClosedError) {
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := sdb.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := sdb.Close(); err != nil {
+		t.Error(err)
+	}
+	wg.Wait()
+	if err := sdb.Query("8.8.8.8", &ip2loc.Record{}, ip2loc.QueryAll); !errors.Is(err, ip2loc.DBClosedError) {
+		t.Errorf("Query after close %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package ip2location
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+)
+
+// index returns the position of the first-16-bits index entry for ip.
+func (m *DBMeta) index(ip Uint128, t IPType) uint32 {
+	switch t {
+	case IPv4:
+		return uint32(ip.Lo>>16)<<3 + m.ipv4index
+	case IPv6:
+		return uint32(ip.Hi>>48)<<3 + m.ipv6index
+	}
+	return 0
+}
+
+// lookup returns the row range to binary search for ip.
+func (m *DBMeta) lookup(r io.ReaderAt, ip Uint128, t IPType) (lo, hi uint32) {
+	switch t {
+	case IPv4:
+		hi = m.ipv4count
+	case IPv6:
+		hi = m.ipv6count
+	default:
+		return
+	}
+	if m.HasIndex(t) {
+		idx := m.index(ip, t)
+		if pos, err := readUint32(r, idx); err == nil {
+			lo = pos
+		}
+		if pos, err := readUint32(r, idx+4); err == nil {
+			hi = pos
+		}
+	}
+	return
+}
+
+// search binary searches the rows of table t for the block containing ip.
+// It checks ctx before reading each row.
+// The returned row offset points past IPFrom so that column offsets can be added to it.
+// Unused rows do not match.
+func (m *DBMeta) search(ctx context.Context, r io.ReaderAt, ip Uint128, t IPType) (row uint32, ipfrom, ipto Uint128, err error) {
+	if !m.Has(t) {
+		err = UnsupportedAddressTypeError
+		return
+	}
+	_, _, _, maxip := m.Indexes(t)
+	low, high := m.lookup(r, ip, t)
+
+	if ip.Cmp(maxip) >= 0 {
+		ip = ip.Sub1()
+	}
+
+	for low <= high {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		mid := ((low + high) >> 1) // (low + high) / 2
+		var unused bool
+		if row, ipfrom, ipto, unused, err = m.row(r, mid, t); err != nil {
+			return
+		}
+
+		if ip.Less(ipfrom) {
+			if mid == 0 {
+				break
+			}
+			high = mid - 1
+			continue
+		}
+		if !ip.Less(ipto) {
+			low = mid + 1
+			continue
+		}
+		if unused {
+			break
+		}
+		return row, ipfrom, ipto, nil
+	}
+	err = NoMatchError
+	return
+}
+
+// row reads the range of row i in table t and whether the row is unused.
+// Unused rows fill the gaps between the blocks of databases that do not cover every address.
+// They have a null first column, which holds a string pointer in every IP2Location layout,
+// so official databases, where unassigned blocks point to "-", have none.
+// The returned row offset points past IPFrom so that column offsets can be added to it.
+func (m *DBMeta) row(r io.ReaderAt, i uint32, t IPType) (row uint32, ipfrom, ipto Uint128, unused bool, err error) {
+	base, _, colsize, _ := m.Indexes(t)
+	o1 := base + (i * colsize)
+	o2 := o1 + colsize
+	var first uint32
+	if ipfrom, first, err = readRowStart(r, o1, t); err != nil {
+		return
+	}
+	if ipto, err = readIPNumber(r, o2, t); err != nil {
+		return
+	}
+	if t == IPv6 {
+		o1 += 12 // coz below is assuming all columns are 4 bytes, so got 12 left to go to make 16 bytes total
+	}
+	return o1, ipfrom, ipto, first == 0, nil
+}
+
+// read the IPFrom column of a row and the column after it with a single read
+func readRowStart(r io.ReaderAt, pos uint32, t IPType) (ipfrom Uint128, first uint32, err error) {
+	data := blank()
+	defer release(data)
+	switch t {
+	case IPv4:
+		if _, err = r.ReadAt(data[:8], int64(pos)-1); err != nil {
+			return
+		}
+		ipfrom.Lo = uint64(binary.LittleEndian.Uint32(data[0:4]))
+		first = binary.LittleEndian.Uint32(data[4:8])
+	case IPv6:
+		if _, err = r.ReadAt(data[:20], int64(pos)-1); err != nil {
+			return
+		}
+		ipfrom.Hi = binary.LittleEndian.Uint64(data[8:16])
+		ipfrom.Lo = binary.LittleEndian.Uint64(data[0:8])
+		first = binary.LittleEndian.Uint32(data[16:20])
+	default:
+		err = InvalidAddressError
+	}
+	return
+}
+
+// read the IPFrom column of a row
+func readIPNumber(r io.ReaderAt, pos uint32, t IPType) (Uint128, error) {
+	switch t {
+	case IPv4:
+		n, err := readUint32(r, pos)
+		return Uint128{Lo: uint64(n)}, err
+	case IPv6:
+		return readUint128(r, pos)
+	}
+	return Uint128{}, InvalidAddressError
+}
@@ -0,0 +1,112 @@
+package ip2location_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"testing"
+
+	ip2loc "github.com/alxarch/ip2location-go"
+)
+
+// partialDB returns a DB1 database covering 1.0.0.0/24, 2.0.0.0-255.255.255.255 and 2001:db8::/32,
+// with unused rows in the gaps.
+func partialDB(t *testing.T) *ip2loc.DB {
+	const v4, v6 = 64, 64 + 5*8
+	const country = v6 + 4*20
+	header := make([]byte, 64)
+	header[0], header[1], header[2], header[3], header[4] = 1, 2, 20, 1, 1
+	binary.LittleEndian.PutUint32(header[5:], 4)
+	binary.LittleEndian.PutUint32(header[9:], v4+1)
+	binary.LittleEndian.PutUint32(header[13:], 3)
+	binary.LittleEndian.PutUint32(header[17:], v6+1)
+	data := header
+	for _, row := range [][2]uint32{
+		{0, 0},
+		{0x01000000, country},
+		{0x01000100, 0},
+		{0x02000000, country},
+		{0xffffffff, 0},
+	} {
+		data = binary.LittleEndian.AppendUint32(data, row[0])
+		data = binary.LittleEndian.AppendUint32(data, row[1])
+	}
+	for _, row := range []struct {
+		hi, lo uint64
+		ptr    uint32
+	}{
+		{0, 0, 0},
+		{0x20010db8 << 32, 0, country},
+		{0x20010db9 << 32, 0, 0},
+		{1<<64 - 1, 1<<64 - 1, 0},
+	} {
+		data = binary.LittleEndian.AppendUint64(data, row.lo)
+		data = binary.LittleEndian.AppendUint64(data, row.hi)
+		data = binary.LittleEndian.AppendUint32(data, row.ptr)
+	}
+	data = append(data, "\x02GR\x06Greece"...)
+	db, err := ip2loc.NewDB(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func Test_UnusedRows(t *testing.T) {
+	db := partialDB(t)
+	for ip, match := range map[string]bool{
+		"0.0.0.0":         false,
+		"0.255.255.255":   false,
+		"1.0.0.0":         true,
+		"1.0.0.255":       true,
+		"1.0.1.0":         false,
+		"1.255.255.255":   false,
+		"2.0.0.0":         true,
+		"255.255.255.255": true,
+		"::1":             false,
+		"2001:db8::":      true,
+		"2001:db8:ffff::": true,
+		"2001:db9::":      false,
+		"ffff::":          false,
+	} {
+		x := ip2loc.Record{}
+		err := db.QueryAddr(netip.MustParseAddr(ip), &x, ip2loc.QueryCountryCode)
+		if !match {
+			if !errors.Is(err, ip2loc.NoMatchError) {
+				t.Errorf("%s: expected no match, got %v %v", ip, x, err)
+			}
+			continue
+		}
+		if err != nil || x.CountryCode != "GR" {
+			t.Errorf("%s: invalid record %v %v", ip, x, err)
+		}
+	}
+
+	for typ, want := range map[ip2loc.IPType][]string{
+		ip2loc.IPv4: {"1.0.0.0-1.0.0.255", "2.0.0.0-255.255.255.255"},
+		ip2loc.IPv6: {"2001:db8::-2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"},
+	} {
+		var got []string
+		c := db.Ranges(typ, ip2loc.QueryCountryName)
+		for c.Next() {
+			r := c.Range()
+			if c.Record().CountryName != "Greece" {
+				t.Errorf("%v: invalid record %v", r, c.Record())
+			}
+			got = append(got, r.Start().String()+"-"+r.End().String())
+		}
+		if err := c.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Errorf("%v: invalid ranges %v", typ, got)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%v: invalid ranges %v", typ, got)
+			}
+		}
+	}
+}
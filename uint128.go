@@ -0,0 +1,151 @@
+package ip2location
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+	"net/netip"
+	"strconv"
+)
+
+// Uint128 is an unsigned 128-bit IP number.
+// IPv4 numbers only use the low 32 bits.
+type Uint128 struct {
+	Hi, Lo uint64
+}
+
+// AddrNumber returns the IP number and type of an address.
+// IPv4-mapped IPv6 addresses are treated as IPv4.
+func AddrNumber(addr netip.Addr) (Uint128, IPType) {
+	addr = addr.Unmap()
+	switch {
+	case addr.Is4():
+		b := addr.As4()
+		return Uint128{Lo: uint64(binary.BigEndian.Uint32(b[:]))}, IPv4
+	case addr.Is6():
+		b := addr.As16()
+		return Uint128{
+			Hi: binary.BigEndian.Uint64(b[:8]),
+			Lo: binary.BigEndian.Uint64(b[8:]),
+		}, IPv6
+	}
+	return Uint128{}, 0
+}
+
+// Addr converts an IP number back to an address of type t.
+func (u Uint128) Addr(t IPType) netip.Addr {
+	switch t {
+	case IPv4:
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(u.Lo))
+		return netip.AddrFrom4(b)
+	case IPv6:
+		var b [16]byte
+		binary.BigEndian.PutUint64(b[:8], u.Hi)
+		binary.BigEndian.PutUint64(b[8:], u.Lo)
+		return netip.AddrFrom16(b)
+	}
+	return netip.Addr{}
+}
+
+// String returns the decimal IP number, as in IP2Location CSV files.
+func (u Uint128) String() string {
+	if u.Hi == 0 {
+		return strconv.FormatUint(u.Lo, 10)
+	}
+	return u.Big().String()
+}
+
+// Cmp compares u and v and returns -1, 0 or +1.
+func (u Uint128) Cmp(v Uint128) int {
+	switch {
+	case u.Hi < v.Hi:
+		return -1
+	case u.Hi > v.Hi:
+		return 1
+	case u.Lo < v.Lo:
+		return -1
+	case u.Lo > v.Lo:
+		return 1
+	}
+	return 0
+}
+
+// Less reports whether u < v.
+func (u Uint128) Less(v Uint128) bool {
+	return u.Hi < v.Hi || (u.Hi == v.Hi && u.Lo < v.Lo)
+}
+
+// IsZero reports whether u == 0.
+func (u Uint128) IsZero() bool {
+	return u.Hi == 0 && u.Lo == 0
+}
+
+// Add1 returns u+1, wrapping around on overflow.
+func (u Uint128) Add1() Uint128 {
+	lo := u.Lo + 1
+	hi := u.Hi
+	if lo == 0 {
+		hi++
+	}
+	return Uint128{hi, lo}
+}
+
+// Sub1 returns u-1, wrapping around on underflow.
+func (u Uint128) Sub1() Uint128 {
+	lo := u.Lo - 1
+	hi := u.Hi
+	if u.Lo == 0 {
+		hi--
+	}
+	return Uint128{hi, lo}
+}
+
+// Add returns u+v, wrapping around on overflow.
+func (u Uint128) Add(v Uint128) Uint128 {
+	lo, carry := bits.Add64(u.Lo, v.Lo, 0)
+	hi, _ := bits.Add64(u.Hi, v.Hi, carry)
+	return Uint128{hi, lo}
+}
+
+// Lsh returns u << n.
+func (u Uint128) Lsh(n uint) Uint128 {
+	switch {
+	case n >= 128:
+		return Uint128{}
+	case n >= 64:
+		return Uint128{u.Lo << (n - 64), 0}
+	case n == 0:
+		return u
+	}
+	return Uint128{u.Hi<<n | u.Lo>>(64-n), u.Lo << n}
+}
+
+// TrailingZeros returns the number of trailing zero bits in u; the result is 128 for u == 0.
+func (u Uint128) TrailingZeros() int {
+	if u.Lo == 0 {
+		return 64 + bits.TrailingZeros64(u.Hi)
+	}
+	return bits.TrailingZeros64(u.Lo)
+}
+
+// Rsh returns u >> n.
+func (u Uint128) Rsh(n uint) Uint128 {
+	switch {
+	case n >= 128:
+		return Uint128{}
+	case n >= 64:
+		return Uint128{0, u.Hi >> (n - 64)}
+	case n == 0:
+		return u
+	}
+	return Uint128{u.Hi >> n, u.Lo>>n | u.Hi<<(64-n)}
+}
+
+// Big returns u as a big.Int.
+func (u Uint128) Big() *big.Int {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], u.Hi)
+	binary.BigEndian.PutUint64(b[8:], u.Lo)
+	return new(big.Int).SetBytes(b[:])
+}